@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Severities of audit findings.
+const (
+	severityError   = "error"   // content some users cannot reach
+	severityWarning = "warning" // content that is harder to use
+)
+
+// auditFinding is one accessibility problem on a page.
+type auditFinding struct {
+	URL      string `json:"url,omitempty"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Path     string `json:"path"` // from nodePath
+	Message  string `json:"message"`
+}
+
+func (f auditFinding) String() string {
+	return fmt.Sprintf("%s: %s %s %s: %s", f.URL, f.Severity, f.Rule, f.Path, f.Message)
+}
+
+// unlabelledInputs are the input types that need a label; the others
+// are hidden or carry their own.
+var unlabelledInputs = map[string]bool{
+	"": true, "text": true, "search": true, "email": true, "url": true, "tel": true,
+	"password": true, "number": true, "range": true, "date": true, "month": true,
+	"week": true, "time": true, "datetime-local": true, "color": true,
+	"checkbox": true, "radio": true, "file": true,
+}
+
+// auditPage checks doc for common accessibility problems, in document order.
+func auditPage(doc *html.Node) []auditFinding {
+	var findings []auditFinding
+	report := func(n *html.Node, rule, severity, format string, args ...interface{}) {
+		findings = append(findings, auditFinding{
+			Rule: rule, Severity: severity, Path: nodePath(n), Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	labelled := make(map[string]bool) // ids named by label for=
+	for _, l := range ElementsByTagName(doc, "label") {
+		if id, ok := attr(l, "for"); ok {
+			labelled[id] = true
+		}
+	}
+	firstWithID := make(map[string]*html.Node)
+	headings := headingProblems(doc)
+
+	forEachNode(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		if id, ok := attr(n, "id"); ok && id != "" {
+			if first := firstWithID[id]; first != nil {
+				report(n, "duplicate-id", severityError, "id %q is already used by %s", id, nodePath(first))
+			} else {
+				firstWithID[id] = n
+			}
+		}
+		if p, ok := headings[n]; ok {
+			report(n, "heading-order", severityWarning, "%s", p)
+		}
+		switch n.Data {
+		case "html":
+			if lang, _ := attr(n, "lang"); strings.TrimSpace(lang) == "" {
+				report(n, "html-lang", severityError, "<html> has no lang attribute")
+			}
+		case "img":
+			if _, ok := attr(n, "alt"); !ok {
+				report(n, "img-alt", severityError, "image %s has no alt text", attrOr(n, "src", "without src"))
+			}
+		case "input", "select", "textarea":
+			if t, _ := attr(n, "type"); n.Data == "input" && !unlabelledInputs[strings.ToLower(t)] {
+				break
+			}
+			id, _ := attr(n, "id")
+			if !labelled[id] && !insideLabel(n) && accessibleName(doc, n, false) == "" {
+				report(n, "form-label", severityError, "%s %s has no label", n.Data, attrOr(n, "name", "without a name"))
+			}
+		case "a":
+			if _, ok := attr(n, "href"); ok && accessibleName(doc, n, true) == "" {
+				report(n, "empty-link", severityError, "link to %s has no text", attrOr(n, "href", ""))
+			}
+		case "button":
+			if accessibleName(doc, n, true) == "" {
+				report(n, "empty-button", severityError, "button has no text")
+			}
+		case "table":
+			if role, _ := attr(n, "role"); role != "presentation" && role != "none" && !hasHeaderCells(n) {
+				report(n, "table-headers", severityWarning, "table has no header cells")
+			}
+		}
+	}, nil)
+	return findings
+}
+
+// attrOr returns the value of the attribute key of n, quoted, or
+// otherwise if it is missing.
+func attrOr(n *html.Node, key, otherwise string) string {
+	if v, ok := attr(n, key); ok {
+		return fmt.Sprintf("%q", v)
+	}
+	return otherwise
+}
+
+func insideLabel(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == "label" {
+			return true
+		}
+	}
+	return false
+}
+
+// accessibleName approximates the name a screen reader gives n: its
+// aria-label, the text of the elements named by aria-labelledby, its
+// contents if fromContent is set, or its title.
+func accessibleName(doc, n *html.Node, fromContent bool) string {
+	if label, _ := attr(n, "aria-label"); strings.TrimSpace(label) != "" {
+		return label
+	}
+	if ids, ok := attr(n, "aria-labelledby"); ok {
+		var parts []string
+		for _, id := range strings.Fields(ids) {
+			if l := ElementByID(doc, id); l != nil {
+				parts = append(parts, textContent(l))
+			}
+		}
+		if name := strings.TrimSpace(strings.Join(parts, " ")); name != "" {
+			return name
+		}
+	}
+	if fromContent {
+		if text := textContent(n); text != "" {
+			return text
+		}
+		for _, img := range ElementsByTagName(n, "img") {
+			if alt, _ := attr(img, "alt"); strings.TrimSpace(alt) != "" {
+				return alt
+			}
+		}
+	}
+	title, _ := attr(n, "title")
+	return strings.TrimSpace(title)
+}
+
+// hasHeaderCells reports whether table has a th of its own, not one in a
+// table nested inside it.
+func hasHeaderCells(table *html.Node) bool {
+	found := false
+	walkNodes(table, func(n *html.Node) walkAction {
+		switch {
+		case n.Type != html.ElementNode:
+		case n.Data == "table" && n != table:
+			return walkSkipChildren
+		case n.Data == "th":
+			found = true
+			return walkStop
+		}
+		return walkContinue
+	}, nil)
+	return found
+}
+
+// auditCommand implements
+// "audit [-crawl] [-limit n] [-timeout d] [-json] [URL|FILE|-]...".
+// It prints one finding per line, or all of them as JSON.
+func auditCommand(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for fetching each URL")
+	crawl := fs.Bool("crawl", false, "crawl from each URL, staying on its host")
+	limit := fs.Int("limit", 100, "pages to fetch per crawl; 0 for no limit")
+	asJSON := fs.Bool("json", false, "print the findings as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	srcs := fs.Args()
+	if len(srcs) == 0 {
+		srcs = []string{"-"}
+	}
+	client := &http.Client{Timeout: *timeout}
+	findings := []auditFinding{}
+	err := eachPage(client, srcs, *crawl, *limit, func(src string, p *page) {
+		for _, f := range auditPage(p.Doc) {
+			f.URL = src
+			findings = append(findings, f)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeFindingsJSON(os.Stdout, findings)
+	}
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	return nil
+}
+
+func writeFindingsJSON(w io.Writer, findings []auditFinding) error {
+	return writeIndentedJSON(w, findings)
+}
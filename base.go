@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// documentBase returns the URL that relative references in doc resolve
+// against. As in the HTML spec, that is the href of the first base element
+// that has one, resolved against docURL, or docURL itself. docURL may be
+// nil when the page's address is unknown; then only an absolute base href
+// counts and the result may be nil.
+func documentBase(doc *html.Node, docURL *url.URL) *url.URL {
+	found := findElements(doc, 1, func(n *html.Node) bool {
+		_, ok := attr(n, "href")
+		return n.Data == "base" && ok
+	})
+	if len(found) == 0 {
+		return docURL
+	}
+	href, _ := attr(found[0], "href")
+	base, err := resolve(docURL, strings.TrimSpace(href))
+	if err != nil || !base.IsAbs() {
+		return docURL
+	}
+	return base
+}
+
+// resolve parses ref relative to base, or on its own if base is nil.
+func resolve(base *url.URL, ref string) (*url.URL, error) {
+	if base == nil {
+		return url.Parse(ref)
+	}
+	return base.Parse(ref)
+}
+
+// canonicalURL returns the href of the first link rel=canonical in doc,
+// resolved against the document base, or "" if there is none.
+func canonicalURL(doc *html.Node, docURL *url.URL) string {
+	found := findElements(doc, 1, func(n *html.Node) bool {
+		rel, _ := attr(n, "rel")
+		_, ok := attr(n, "href")
+		return n.Data == "link" && ok && hasToken(rel, "canonical")
+	})
+	if len(found) == 0 {
+		return ""
+	}
+	href, _ := attr(found[0], "href")
+	u, err := resolve(documentBase(doc, docURL), strings.TrimSpace(href))
+	if err != nil || !u.IsAbs() {
+		return ""
+	}
+	return u.String()
+}
+
+// hasToken reports whether the space separated list has token, ignoring case.
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// extraction is what ExtractPage finds on a page.
+type extraction struct {
+	URL       string       `json:"url"`  // after redirects
+	Base      string       `json:"base"` // what relative links resolve against
+	Canonical string       `json:"canonical,omitempty"`
+	Encoding  pageEncoding `json:"encoding"` // what the page was decoded from
+	Links     []string     `json:"links"`
+}
+
+// loadURL is loadPage for the Extract functions, which fetch only http
+// and https URLs. Any other URL, such as a mailto: or ftp: link found
+// while crawling, is an error rather than a local file name.
+func loadURL(url string) (*page, error) {
+	if !isURL(url) {
+		return nil, fmt.Errorf("getting %s: not an http or https URL", url)
+	}
+	return loadPage(context.Background(), http.DefaultClient, url)
+}
+
+// ExtractPage is Extract that also reports the page's base and canonical
+// URLs and its encoding.
+func ExtractPage(url string) (*extraction, error) {
+	p, err := loadURL(url)
+	if err != nil {
+		return nil, err
+	}
+	e := &extraction{Canonical: canonicalURL(p.Doc, p.URL), Encoding: p.Encoding, Links: pageLinks(p.Doc, p.URL)}
+	if p.URL != nil {
+		e.URL = p.URL.String()
+	}
+	if base := documentBase(p.Doc, p.URL); base != nil {
+		e.Base = base.String()
+	}
+	return e, nil
+}
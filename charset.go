@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// pageEncoding is the character encoding a page was decoded from.
+type pageEncoding struct {
+	Name string `json:"name"` // as in the WHATWG Encoding Standard, such as "shift_jis"
+	// Source is where the name came from: "bom", "header" for the
+	// Content-Type header, or "document" when it was read from a meta
+	// element or, failing that, guessed from the bytes themselves.
+	Source string `json:"source"`
+}
+
+// prescanBytes is how much of a page is searched for a meta charset.
+const prescanBytes = 1024
+
+var byteOrderMarks = []string{"\xef\xbb\xbf", "\xfe\xff", "\xff\xfe"}
+
+// decodeHTML returns r transcoded to UTF-8, with any byte order mark
+// removed, and the encoding it was in, as charset.DetermineEncoding finds
+// it in contentType and the first 1024 bytes.
+func decodeHTML(r io.Reader, contentType string) (io.Reader, pageEncoding, error) {
+	br := bufio.NewReaderSize(r, prescanBytes)
+	preview, err := br.Peek(prescanBytes)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, pageEncoding{}, err
+	}
+	sample := preview
+	if len(preview) < prescanBytes {
+		// The preview is the whole page, so it cannot end in a partial
+		// rune; DetermineEncoding drops a trailing multibyte rune as if it
+		// could, unless something follows it.
+		sample = append(preview[:len(preview):len(preview)], '\n')
+	}
+	e, name, certain := charset.DetermineEncoding(sample, contentType)
+	enc := pageEncoding{Name: name, Source: "document"}
+	if certain {
+		enc.Source = "header"
+		for _, bom := range byteOrderMarks {
+			if bytes.HasPrefix(preview, []byte(bom)) {
+				enc.Source = "bom"
+				br.Discard(len(bom))
+				break
+			}
+		}
+	}
+	if name == "utf-8" {
+		return br, enc, nil // html.Parse reads UTF-8 as it is
+	}
+	return transform.NewReader(br, e.NewDecoder()), enc, nil
+}
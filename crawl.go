@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/html"
+)
+
+// crawlPages fetches pages breadth-first from start, staying on its host,
+// and calls visit with the URL it fetched and each page that parses; the
+// page's own URL is where any redirects ended. Fetch and parse errors are
+// logged and the page skipped, as is a page whose canonical URL was seen
+// on an earlier page. At most limit pages are fetched; zero means no limit.
+func crawlPages(ctx context.Context, client *http.Client, start string, limit int, visit func(url string, p *page)) error {
+	u, err := url.Parse(start)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return fmt.Errorf("crawling %s: not an absolute URL", start)
+	}
+	fetched := 0
+	canonicals := make(map[string]bool)
+	breadthFirst(func(item, host string) []string {
+		if limit > 0 && fetched >= limit || ctx.Err() != nil {
+			return nil
+		}
+		fetched++
+		resp, err := fetch(ctx, client, item)
+		if err != nil {
+			log.Print(err)
+			return nil
+		}
+		r, enc, err := decodeHTML(resp.Body, resp.Header.Get("Content-Type"))
+		if err != nil {
+			resp.Body.Close()
+			log.Printf("reading %s: %v", item, err)
+			return nil
+		}
+		doc, err := html.Parse(r)
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("parsing %s as HTML: %v", item, err)
+			return nil
+		}
+		canonical := canonicalURL(doc, resp.Request.URL)
+		if canonical == "" {
+			canonical = resp.Request.URL.String()
+		}
+		if canonicals[canonical] {
+			return nil
+		}
+		canonicals[canonical] = true
+		visit(item, &page{doc, resp.Request.URL, enc})
+		return sameHostLinks(pageLinks(doc, resp.Request.URL), host)
+	}, []string{start}, u.Host)
+	return ctx.Err()
+}
+
+// sameHostLinks returns the http and https links to host, without their
+// fragments so that each page is fetched once.
+func sameHostLinks(links []string, host string) []string {
+	var kept []string
+	for _, link := range links {
+		u, err := url.Parse(link)
+		if err != nil || u.Host != host || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+		kept = append(kept, u.String())
+	}
+	return kept
+}
+
+// eachPage calls visit with every page named by srcs, as loadPage opens
+// them. If crawl is set, each URL is crawled instead, up to limit pages.
+func eachPage(client *http.Client, srcs []string, crawl bool, limit int, visit func(src string, p *page)) error {
+	for _, src := range srcs {
+		if crawl && isURL(src) {
+			if err := crawlPages(context.Background(), client, src, limit, visit); err != nil {
+				return err
+			}
+			continue
+		}
+		p, err := loadPage(context.Background(), client, src)
+		if err != nil {
+			return err
+		}
+		visit(src, p)
+	}
+	return nil
+}
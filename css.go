@@ -0,0 +1,538 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A cssSelector is a compiled group of CSS Level 3 selectors such as
+// "a.nav > span, meta[property^=og:]".
+type cssSelector []*complexSelector
+
+// complexSelector is a chain of compound selectors joined by combinators:
+// combinators[i] sits between parts[i] and parts[i+1] and is one of
+// ' ' (descendant), '>' (child), '+' (next sibling) or '~' (later sibling).
+type complexSelector struct {
+	parts       []compoundSelector
+	combinators []byte
+}
+
+// compoundSelector holds the simple selectors that must all match one
+// element, like the tag, class and attribute tests of "a.nav[href]".
+type compoundSelector []func(n *html.Node) bool
+
+// selectorError describes why a selector could not be compiled.
+type selectorError struct {
+	Selector string
+	Offset   int
+	Msg      string
+}
+
+func (e *selectorError) Error() string {
+	return fmt.Sprintf("selector %q: %s at offset %d", e.Selector, e.Msg, e.Offset)
+}
+
+// compileSelector parses a comma separated list of CSS Level 3 selectors.
+// Pseudo-elements, namespaces and dynamic pseudo-classes such as :hover
+// are rejected with an error that names them.
+func compileSelector(s string) (cssSelector, error) {
+	p := &selectorParser{src: s}
+	var group cssSelector
+	for {
+		p.skipSpace()
+		c, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		group = append(group, c)
+		p.skipSpace()
+		if p.eof() {
+			return group, nil
+		}
+		if p.peek() != ',' {
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+		p.pos++
+	}
+}
+
+// Match reports whether the element n matches any selector of the group.
+func (sel cssSelector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, c := range sel {
+		if c.matchAt(n, len(c.parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAt matches parts[:i+1] right to left, starting with n.
+func (c *complexSelector) matchAt(n *html.Node, i int) bool {
+	if !c.parts[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c.combinators[i-1] {
+	case '>':
+		p := n.Parent
+		return p != nil && p.Type == html.ElementNode && c.matchAt(p, i-1)
+	case '+':
+		p := prevElement(n)
+		return p != nil && c.matchAt(p, i-1)
+	case '~':
+		for p := prevElement(n); p != nil; p = prevElement(p) {
+			if c.matchAt(p, i-1) {
+				return true
+			}
+		}
+		return false
+	}
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if c.matchAt(p, i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cs compoundSelector) match(n *html.Node) bool {
+	for _, f := range cs {
+		if !f(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func prevElement(n *html.Node) *html.Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func nextElement(n *html.Node) *html.Node {
+	for p := n.NextSibling; p != nil; p = p.NextSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+// QuerySelector returns the first element under doc matching sel, or nil.
+// The walk stops at the first match.
+func QuerySelector(doc *html.Node, sel string) (*html.Node, error) {
+	s, err := compileSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	found := findElements(doc, 1, s.Match)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found[0], nil
+}
+
+// QuerySelectorAll returns every element under doc matching sel, in
+// document order.
+func QuerySelectorAll(doc *html.Node, sel string) ([]*html.Node, error) {
+	s, err := compileSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	return findElements(doc, 0, s.Match), nil
+}
+
+type selectorParser struct {
+	src string
+	pos int
+}
+
+func (p *selectorParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *selectorParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return &selectorError{p.src, p.pos, fmt.Sprintf(format, args...)}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// skipSpace skips white space and reports whether there was any.
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && isSpace(p.peek()) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *selectorParser) parseComplex() (*complexSelector, error) {
+	c := &complexSelector{}
+	for {
+		cs, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		c.parts = append(c.parts, cs)
+
+		space := p.skipSpace()
+		switch p.peek() {
+		case '>', '+', '~':
+			c.combinators = append(c.combinators, p.peek())
+			p.pos++
+			p.skipSpace()
+		case ',', 0:
+			return c, nil
+		default:
+			if !space {
+				return nil, p.errorf("unexpected %q", p.peek())
+			}
+			c.combinators = append(c.combinators, ' ')
+		}
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// parseName reads an identifier or name, handling backslash escapes of a
+// single character.
+func (p *selectorParser) parseName() (string, error) {
+	var b strings.Builder
+loop:
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '\\' && p.pos+1 < len(p.src):
+			b.WriteByte(p.src[p.pos+1])
+			p.pos += 2
+		case isNameChar(c):
+			b.WriteByte(c)
+			p.pos++
+		default:
+			break loop
+		}
+	}
+	if b.Len() == 0 {
+		if p.eof() {
+			return "", p.errorf("unexpected end of selector")
+		}
+		return "", p.errorf("expected a name, found %q", p.peek())
+	}
+	return b.String(), nil
+}
+
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	var cs compoundSelector
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		cs = append(cs, func(n *html.Node) bool { return true })
+	case isNameChar(c) || c == '\\':
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		name = strings.ToLower(name)
+		cs = append(cs, func(n *html.Node) bool { return n.Data == name })
+	}
+	if p.peek() == '|' {
+		return nil, p.errorf("namespaces are not supported")
+	}
+	for {
+		var f func(*html.Node) bool
+		var err error
+		switch p.peek() {
+		case '#':
+			p.pos++
+			var id string
+			if id, err = p.parseName(); err == nil {
+				f = func(n *html.Node) bool { v, ok := attr(n, "id"); return ok && v == id }
+			}
+		case '.':
+			p.pos++
+			var class string
+			if class, err = p.parseName(); err == nil {
+				f = attrMatcher("class", "~=", class)
+			}
+		case '[':
+			f, err = p.parseAttribute()
+		case ':':
+			f, err = p.parsePseudo()
+		default:
+			if len(cs) == 0 {
+				if p.eof() {
+					return nil, p.errorf("expected a selector")
+				}
+				return nil, p.errorf("unexpected %q", p.peek())
+			}
+			return cs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, f)
+	}
+}
+
+func (p *selectorParser) parseAttribute() (func(*html.Node) bool, error) {
+	p.pos++ // [
+	p.skipSpace()
+	key, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	key = strings.ToLower(key)
+	if p.peek() == '|' && p.pos+1 < len(p.src) && p.src[p.pos+1] != '=' {
+		return nil, p.errorf("namespaces are not supported")
+	}
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		return func(n *html.Node) bool { _, ok := attr(n, key); return ok }, nil
+	}
+	var op string
+	for _, o := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.src[p.pos:], o) {
+			op = o
+		}
+	}
+	if op == "" {
+		return nil, p.errorf("unknown attribute operator")
+	}
+	p.pos += len(op)
+	p.skipSpace()
+	var value string
+	if q := p.peek(); q == '"' || q == '\'' {
+		end := strings.IndexByte(p.src[p.pos+1:], q)
+		if end < 0 {
+			return nil, p.errorf("unterminated string")
+		}
+		value = p.src[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		// Be lenient with unquoted values such as og: that are not
+		// identifiers: take everything up to ] or white space.
+		start := p.pos
+		for !p.eof() && p.peek() != ']' && !isSpace(p.peek()) {
+			p.pos++
+		}
+		if p.pos == start {
+			return nil, p.errorf("expected an attribute value")
+		}
+		value = p.src[start:p.pos]
+	}
+	p.skipSpace()
+	if p.peek() != ']' {
+		return nil, p.errorf("expected ]")
+	}
+	p.pos++
+	return attrMatcher(key, op, value), nil
+}
+
+// attrMatcher implements the attribute selector [key op value].
+func attrMatcher(key, op, value string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		v, ok := attr(n, key)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "=":
+			return v == value
+		case "~=":
+			for _, f := range strings.Fields(v) {
+				if f == value {
+					return true
+				}
+			}
+			return false
+		case "|=":
+			return v == value || strings.HasPrefix(v, value+"-")
+		case "^=":
+			return value != "" && strings.HasPrefix(v, value)
+		case "$=":
+			return value != "" && strings.HasSuffix(v, value)
+		}
+		return value != "" && strings.Contains(v, value)
+	}
+}
+
+func (p *selectorParser) parsePseudo() (func(*html.Node) bool, error) {
+	p.pos++ // :
+	if p.peek() == ':' {
+		return nil, p.errorf("pseudo-elements are not supported")
+	}
+	start := p.pos
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+	if p.peek() != '(' {
+		switch name {
+		case "root":
+			return func(n *html.Node) bool { return n.Parent != nil && n.Parent.Type == html.DocumentNode }, nil
+		case "empty":
+			return isEmptyElement, nil
+		case "first-child":
+			return nthMatcher(0, 1, false, false), nil
+		case "last-child":
+			return nthMatcher(0, 1, false, true), nil
+		case "only-child":
+			return both(nthMatcher(0, 1, false, false), nthMatcher(0, 1, false, true)), nil
+		case "first-of-type":
+			return nthMatcher(0, 1, true, false), nil
+		case "last-of-type":
+			return nthMatcher(0, 1, true, true), nil
+		case "only-of-type":
+			return both(nthMatcher(0, 1, true, false), nthMatcher(0, 1, true, true)), nil
+		case "checked":
+			return func(n *html.Node) bool {
+				_, checked := attr(n, "checked")
+				_, selected := attr(n, "selected")
+				return n.Data == "input" && checked || n.Data == "option" && selected
+			}, nil
+		case "disabled":
+			return func(n *html.Node) bool { _, ok := attr(n, "disabled"); return formElements[n.Data] && ok }, nil
+		case "enabled":
+			return func(n *html.Node) bool { _, ok := attr(n, "disabled"); return formElements[n.Data] && !ok }, nil
+		}
+		p.pos = start
+		return nil, p.errorf("unsupported pseudo-class :%s", name)
+	}
+	p.pos++ // (
+	p.skipSpace()
+	var f func(*html.Node) bool
+	switch name {
+	case "not":
+		cs, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		f = func(n *html.Node) bool { return !cs.match(n) }
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		end := strings.IndexByte(p.src[p.pos:], ')')
+		if end < 0 {
+			return nil, p.errorf("expected )")
+		}
+		a, b, ok := parseNth(p.src[p.pos : p.pos+end])
+		if !ok {
+			return nil, p.errorf("bad argument to :%s", name)
+		}
+		p.pos += end
+		f = nthMatcher(a, b, strings.HasSuffix(name, "of-type"), strings.Contains(name, "last"))
+	default:
+		p.pos = start
+		return nil, p.errorf("unsupported pseudo-class :%s()", name)
+	}
+	p.skipSpace()
+	if p.peek() != ')' {
+		return nil, p.errorf("expected )")
+	}
+	p.pos++
+	return f, nil
+}
+
+// formElements can be :enabled or :disabled.
+var formElements = map[string]bool{
+	"button": true, "fieldset": true, "input": true, "optgroup": true,
+	"option": true, "select": true, "textarea": true,
+}
+
+func both(f, g func(*html.Node) bool) func(*html.Node) bool {
+	return func(n *html.Node) bool { return f(n) && g(n) }
+}
+
+func isEmptyElement(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode || c.Type == html.TextNode && c.Data != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNth parses the an+b argument of the :nth-* pseudo-classes,
+// including "odd" and "even".
+func parseNth(s string) (a, b int, ok bool) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, true
+	case "even":
+		return 2, 0, true
+	}
+	i := strings.IndexByte(s, 'n')
+	if i < 0 {
+		b, err := strconv.Atoi(s)
+		return 0, b, err == nil
+	}
+	switch s[:i] {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(s[:i]); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rest := s[i+1:]; rest != "" {
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, false
+		}
+		var err error
+		if b, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, false
+		}
+	}
+	return a, b, true
+}
+
+// nthMatcher matches elements whose 1-based position among their element
+// siblings, counted from the end if fromEnd and among siblings of the same
+// type if ofType, is a*k+b for some k >= 0.
+func nthMatcher(a, b int, ofType, fromEnd bool) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		if n.Parent == nil {
+			return false
+		}
+		pos := 1
+		step := prevElement
+		if fromEnd {
+			step = nextElement
+		}
+		for s := step(n); s != nil; s = step(s) {
+			if !ofType || s.Data == n.Data {
+				pos++
+			}
+		}
+		if a == 0 {
+			return pos == b
+		}
+		return (pos-b)%a == 0 && (pos-b)/a >= 0
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+// denseGraph is a prerequisite graph whose courses are interned to int32 IDs.
+// The prerequisites of v are edges[offsets[v]:offsets[v+1]], so the whole
+// graph lives in a few flat slices instead of one map per course.
+type denseGraph struct {
+	names   []string
+	ids     map[string]int32
+	offsets []int32
+	edges   []int32
+}
+
+func newDenseGraph() *denseGraph {
+	return &denseGraph{ids: make(map[string]int32)}
+}
+
+// intern returns the ID of name, assigning the next free one if needed.
+func (g *denseGraph) intern(name string) int32 {
+	if id, ok := g.ids[name]; ok {
+		return id
+	}
+	id := int32(len(g.names))
+	g.ids[name] = id
+	g.names = append(g.names, name)
+	return id
+}
+
+// freeze stores the edges from[i] -> to[i], keeping the order in which the
+// prerequisites of each course were given.
+func (g *denseGraph) freeze(from, to []int32) {
+	n := len(g.names)
+	g.offsets = make([]int32, n+1)
+	for _, f := range from {
+		g.offsets[f+1]++
+	}
+	for i := 0; i < n; i++ {
+		g.offsets[i+1] += g.offsets[i]
+	}
+	g.edges = make([]int32, len(to))
+	fill := append([]int32(nil), g.offsets[:n]...)
+	for i, f := range from {
+		g.edges[fill[f]] = to[i]
+		fill[f]++
+	}
+}
+
+// internPrereqs converts a prereqs style map. IDs follow the map's
+// iteration order.
+func internPrereqs(m map[string]map[string]bool) *denseGraph {
+	g := newDenseGraph()
+	var from, to []int32
+	for course, deps := range m {
+		c := g.intern(course)
+		for d := range deps {
+			from = append(from, c)
+			to = append(to, g.intern(d))
+		}
+	}
+	g.freeze(from, to)
+	return g
+}
+
+// internLists converts a cyclePrereqs style map. The courses in keys get
+// the first IDs, in that order.
+func internLists(m map[string][]string, keys []string) *denseGraph {
+	g := newDenseGraph()
+	for _, k := range keys {
+		g.intern(k)
+	}
+	var from, to []int32
+	for _, k := range keys {
+		c := g.ids[k]
+		for _, d := range m[k] {
+			from = append(from, c)
+			to = append(to, g.intern(d))
+		}
+	}
+	g.freeze(from, to)
+	return g
+}
+
+// allIDs returns every ID of g in increasing order.
+func (g *denseGraph) allIDs() []int32 {
+	ids := make([]int32, len(g.names))
+	for i := range ids {
+		ids[i] = int32(i)
+	}
+	return ids
+}
+
+// namesOf maps IDs back to course names.
+func (g *denseGraph) namesOf(ids []int32) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = g.names[id]
+	}
+	return names
+}
+
+// postorder runs a depth-first search from each root in turn, using an
+// explicit stack, and returns the reached nodes in postorder: every
+// prerequisite comes before the courses that need it. If onCycle is not nil
+// it is called for every back edge with the cycle it closes, starting and
+// ending with the same node.
+func (g *denseGraph) postorder(roots []int32, onCycle func(path []int32)) []int32 {
+	const (
+		white = iota // not visited
+		grey         // on the stack
+		black        // finished
+	)
+	n := len(g.names)
+	state := make([]uint8, n)
+	next := make([]int32, n)  // next edge to follow for a grey node
+	depth := make([]int32, n) // stack index of a grey node
+	order := make([]int32, 0, n)
+	var stack []int32
+	push := func(v int32) {
+		state[v] = grey
+		next[v] = g.offsets[v]
+		depth[v] = int32(len(stack))
+		stack = append(stack, v)
+	}
+	for _, r := range roots {
+		if state[r] != white {
+			continue
+		}
+		push(r)
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			if next[v] == g.offsets[v+1] {
+				stack = stack[:len(stack)-1]
+				state[v] = black
+				order = append(order, v)
+				continue
+			}
+			w := g.edges[next[v]]
+			next[v]++
+			switch state[w] {
+			case white:
+				push(w)
+			case grey:
+				if onCycle != nil {
+					path := append([]int32(nil), stack[depth[w]:]...)
+					onCycle(append(path, w))
+				}
+			}
+		}
+	}
+	return order
+}
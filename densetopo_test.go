@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+// chainGraph returns n courses where course i requires course i+1,
+// so a depth-first search from course 0 goes n levels deep.
+func chainGraph(n int) *denseGraph {
+	g := newDenseGraph()
+	from := make([]int32, 0, n)
+	to := make([]int32, 0, n)
+	for i := 0; i < n; i++ {
+		g.intern(strconv.Itoa(i))
+	}
+	for i := 0; i+1 < n; i++ {
+		from = append(from, int32(i))
+		to = append(to, int32(i+1))
+	}
+	g.freeze(from, to)
+	return g
+}
+
+// fanGraph returns n courses that all require course 0.
+func fanGraph(n int) *denseGraph {
+	g := newDenseGraph()
+	from := make([]int32, 0, n)
+	to := make([]int32, 0, n)
+	for i := 0; i < n; i++ {
+		g.intern(strconv.Itoa(i))
+	}
+	for i := 1; i < n; i++ {
+		from = append(from, int32(i))
+		to = append(to, 0)
+	}
+	g.freeze(from, to)
+	return g
+}
+
+const benchCourses = 1000000
+
+func BenchmarkChain1M(b *testing.B) {
+	g := chainGraph(benchCourses)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.postorder(g.allIDs(), nil)
+	}
+}
+
+func BenchmarkFan1M(b *testing.B) {
+	g := fanGraph(benchCourses)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.postorder(g.allIDs(), nil)
+	}
+}
+
+// BenchmarkChain1MMap is BenchmarkChain1M starting from a map of names,
+// so interning is included.
+func BenchmarkChain1MMap(b *testing.B) {
+	chain := chainGraph(benchCourses)
+	m := make(map[string][]string, benchCourses)
+	for i := 0; i+1 < benchCourses; i++ {
+		m[chain.names[i]] = []string{chain.names[i+1]}
+	}
+	keys := chain.names[:benchCourses-1]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g := internLists(m, keys)
+		g.postorder(g.allIDs(), nil)
+	}
+}
+
+// TestDeepChain sorts a chain too deep for a recursive depth-first search
+// through the public sorts, starting from maps of names.
+func TestDeepChain(t *testing.T) {
+	const depth = 500000
+	m := genChain(depth)
+	if o := topoSort(m); !isOrderingOf(m, o) {
+		t.Errorf("topoSort: invalid order of %d courses", len(o.Courses))
+	}
+	if o := cycleTopoSort(toLists(m)); !isOrderingOf(m, o) {
+		t.Errorf("cycleTopoSort: invalid order of %d courses", len(o.Courses))
+	}
+}
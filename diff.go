@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// closureChange lists how the transitive prerequisites of a course changed.
+type closureChange struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// graphDiff is the structural difference between two prerequisite graphs.
+type graphDiff struct {
+	AddedCourses   []string                 `json:"addedCourses"`
+	RemovedCourses []string                 `json:"removedCourses"`
+	AddedEdges     []prereqEdge             `json:"addedEdges"`
+	RemovedEdges   []prereqEdge             `json:"removedEdges"`
+	NewCycles      [][]string               `json:"newCycles"`
+	Closure        map[string]closureChange `json:"closureChanged"`
+}
+
+// diffGraphs compares the catalog before and after a change.
+func diffGraphs(before, after map[string][]string) graphDiff {
+	d := graphDiff{Closure: make(map[string]closureChange)}
+	oldNodes, oldEdges := listGraph(before)
+	newNodes, newEdges := listGraph(after)
+	d.AddedCourses, d.RemovedCourses = diffStrings(oldNodes, newNodes)
+
+	oldSet := make(map[prereqEdge]bool)
+	for _, e := range oldEdges {
+		oldSet[e] = true
+	}
+	newSet := make(map[prereqEdge]bool)
+	for _, e := range newEdges {
+		newSet[e] = true
+		if !oldSet[e] {
+			d.AddedEdges = append(d.AddedEdges, e)
+		}
+	}
+	for _, e := range oldEdges {
+		if !newSet[e] {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+
+	d.NewCycles = cyclesThrough(after, d.AddedEdges)
+
+	for _, course := range unionStrings(oldNodes, newNodes) {
+		added, removed := diffStrings(closureOf(before, course), closureOf(after, course))
+		if len(added) > 0 || len(removed) > 0 {
+			d.Closure[course] = closureChange{added, removed}
+		}
+	}
+	return d
+}
+
+// diffStrings returns the elements only in b and the elements only in a.
+// Both inputs and outputs are sorted.
+func diffStrings(a, b []string) (added, removed []string) {
+	inA := make(map[string]bool)
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool)
+	for _, s := range b {
+		inB[s] = true
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+func unionStrings(a, b []string) []string {
+	seen := make(map[string]bool)
+	var all []string
+	for _, s := range append(append([]string(nil), a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			all = append(all, s)
+		}
+	}
+	sort.Strings(all)
+	return all
+}
+
+// closureOf returns the sorted transitive prerequisites of course.
+func closureOf(m map[string][]string, course string) []string {
+	seen := make(map[string]bool)
+	worklist := []string{course}
+	for len(worklist) > 0 {
+		item := worklist[0]
+		worklist = worklist[1:]
+		for _, d := range m[item] {
+			if !seen[d] {
+				seen[d] = true
+				worklist = append(worklist, d)
+			}
+		}
+	}
+	var list []string
+	for c := range seen {
+		list = append(list, c)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// cyclesThrough returns, for each edge in edges that closes a cycle in m,
+// the shortest such cycle, without repeats. Every cycle of m that was not
+// in the graph before the edges were added runs through one of them, and
+// unlike the back edges of a depth-first search the result does not
+// depend on the order the courses are visited in. Each cycle is rotated
+// to start at its smallest course and closed by repeating it.
+func cyclesThrough(m map[string][]string, edges []prereqEdge) [][]string {
+	var cycles [][]string
+	seen := make(map[string]bool)
+	for _, e := range edges {
+		path := shortestPath(m, e.Prereq, e.Course)
+		if path == nil {
+			continue
+		}
+		min := 0
+		for i, n := range path {
+			if n < path[min] {
+				min = i
+			}
+		}
+		c := append(append([]string(nil), path[min:]...), path[:min]...)
+		c = append(c, c[0])
+		if key := strings.Join(c, "\x00"); !seen[key] {
+			seen[key] = true
+			cycles = append(cycles, c)
+		}
+	}
+	return cycles
+}
+
+// shortestPath returns the courses on a shortest path of prerequisite
+// edges from one course to another, both included, or nil if there is none.
+func shortestPath(m map[string][]string, from, to string) []string {
+	prev := map[string]string{from: ""}
+	worklist := []string{from}
+	for len(worklist) > 0 {
+		item := worklist[0]
+		worklist = worklist[1:]
+		if item == to {
+			var path []string
+			for c := to; c != ""; c = prev[c] {
+				path = append([]string{c}, path...)
+			}
+			return path
+		}
+		for _, d := range m[item] {
+			if _, ok := prev[d]; !ok {
+				prev[d] = item
+				worklist = append(worklist, d)
+			}
+		}
+	}
+	return nil
+}
+
+// writeText prints the diff for reviewers, one change per line.
+func (d graphDiff) writeText(w io.Writer) {
+	for _, c := range d.AddedCourses {
+		fmt.Fprintf(w, "+ course %s\n", c)
+	}
+	for _, c := range d.RemovedCourses {
+		fmt.Fprintf(w, "- course %s\n", c)
+	}
+	for _, e := range d.AddedEdges {
+		fmt.Fprintf(w, "+ edge   %s\n", e)
+	}
+	for _, e := range d.RemovedEdges {
+		fmt.Fprintf(w, "- edge   %s\n", e)
+	}
+	for _, c := range d.NewCycles {
+		fmt.Fprintf(w, "! cycle: %s\n", strings.Join(c, " -> "))
+	}
+	var courses []string
+	for c := range d.Closure {
+		courses = append(courses, c)
+	}
+	sort.Strings(courses)
+	for _, c := range courses {
+		ch := d.Closure[c]
+		fmt.Fprintf(w, "~ %s:", c)
+		for _, a := range ch.Added {
+			fmt.Fprintf(w, " +%s", a)
+		}
+		for _, r := range ch.Removed {
+			fmt.Fprintf(w, " -%s", r)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeJSON writes the diff as indented JSON.
+func (d graphDiff) writeJSON(w io.Writer) error {
+	return writeIndentedJSON(w, d)
+}
+
+// loadCatalog reads a catalog in the cyclePrereqs style from a JSON file
+// that maps each course to its list of prerequisites.
+func loadCatalog(name string) (map[string][]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var m map[string][]string
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("parsing %s as a catalog: %v", name, err)
+	}
+	return m, nil
+}
+
+// diffCommand implements "diff [-json] BEFORE AFTER". It prints the
+// difference between two catalog files, as text or as JSON.
+func diffCommand(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the diff as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: diff [-json] BEFORE AFTER")
+	}
+	before, err := loadCatalog(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := loadCatalog(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	d := diffGraphs(before, after)
+	if *asJSON {
+		return d.writeJSON(os.Stdout)
+	}
+	d.writeText(os.Stdout)
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// cycleError is returned when adding a prerequisite would close a cycle.
+// Path lists courses from a course to its prerequisite, like checkCycle,
+// and ends with the course it started from.
+type cycleError struct {
+	Path []string
+}
+
+func (e *cycleError) Error() string {
+	return "cycle: " + strings.Join(e.Path, " -> ")
+}
+
+// dynamicOrder keeps a topological order of a prerequisite graph valid while
+// edges are added and removed one at a time (Pearce-Kelly). Only the courses
+// between the two ends of a violating edge are moved; every other course
+// keeps its position.
+type dynamicOrder struct {
+	order []string                   // position -> course
+	pos   map[string]int             // course -> position
+	next  map[string]map[string]bool // prerequisite -> courses that need it
+	prev  map[string]map[string]bool // course -> its prerequisites
+}
+
+func newDynamicOrder() *dynamicOrder {
+	return &dynamicOrder{
+		pos:  make(map[string]int),
+		next: make(map[string]map[string]bool),
+		prev: make(map[string]map[string]bool),
+	}
+}
+
+// dynamicOrderOf builds a dynamicOrder from a prerequisite map, adding the
+// courses in sorted order and then every edge.
+func dynamicOrderOf(m map[string]map[string]bool) (*dynamicOrder, error) {
+	d := newDynamicOrder()
+	for _, n := range graphNodes(m) {
+		d.AddNode(n)
+	}
+	for _, course := range graphNodes(m) {
+		for prereq := range m[course] {
+			if err := d.AddEdge(course, prereq); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// AddNode appends course at the end of the order if it is not known yet.
+func (d *dynamicOrder) AddNode(course string) {
+	if _, ok := d.pos[course]; ok {
+		return
+	}
+	d.pos[course] = len(d.order)
+	d.order = append(d.order, course)
+	d.next[course] = make(map[string]bool)
+	d.prev[course] = make(map[string]bool)
+}
+
+// AddEdge records that course requires prereq and repairs the order.
+// If the edge would create a cycle the graph is left unchanged and a
+// *cycleError describing the cycle is returned.
+func (d *dynamicOrder) AddEdge(course, prereq string) error {
+	d.AddNode(course)
+	d.AddNode(prereq)
+	if d.next[prereq][course] {
+		return nil
+	}
+	if course == prereq {
+		return &cycleError{[]string{course, course}}
+	}
+	lb, ub := d.pos[course], d.pos[prereq]
+	if lb < ub {
+		// Find everything reachable from course that sits before prereq.
+		parent := make(map[string]string)
+		forward := []string{course}
+		visited := map[string]bool{course: true}
+		for i := 0; i < len(forward); i++ {
+			for n := range d.next[forward[i]] {
+				if n == prereq {
+					path := []string{prereq}
+					for c := forward[i]; c != course; c = parent[c] {
+						path = append(path, c)
+					}
+					return &cycleError{append(path, course, prereq)}
+				}
+				if !visited[n] && d.pos[n] < ub {
+					visited[n] = true
+					parent[n] = forward[i]
+					forward = append(forward, n)
+				}
+			}
+		}
+		// And everything prereq depends on that sits after course.
+		backward := []string{prereq}
+		visited = map[string]bool{prereq: true}
+		for i := 0; i < len(backward); i++ {
+			for n := range d.prev[backward[i]] {
+				if !visited[n] && d.pos[n] > lb {
+					visited[n] = true
+					backward = append(backward, n)
+				}
+			}
+		}
+		d.reorder(backward, forward)
+	}
+	d.next[prereq][course] = true
+	d.prev[course][prereq] = true
+	return nil
+}
+
+// reorder moves the backward set in front of the forward set, reusing the
+// positions the two sets already occupy.
+func (d *dynamicOrder) reorder(backward, forward []string) {
+	byPos := func(s []string) {
+		sort.Slice(s, func(i, j int) bool { return d.pos[s[i]] < d.pos[s[j]] })
+	}
+	byPos(backward)
+	byPos(forward)
+	var slots []int
+	for _, n := range backward {
+		slots = append(slots, d.pos[n])
+	}
+	for _, n := range forward {
+		slots = append(slots, d.pos[n])
+	}
+	sort.Ints(slots)
+	for i, n := range append(backward, forward...) {
+		d.pos[n] = slots[i]
+		d.order[slots[i]] = n
+	}
+}
+
+// RemoveEdge forgets that course requires prereq. The current order stays
+// valid, so nothing moves.
+func (d *dynamicOrder) RemoveEdge(course, prereq string) {
+	if d.next[prereq] != nil {
+		delete(d.next[prereq], course)
+	}
+	if d.prev[course] != nil {
+		delete(d.prev[course], prereq)
+	}
+}
+
+// Order returns the courses in their current topological order.
+func (d *dynamicOrder) Order() Ordering {
+	return newOrdering(append([]string(nil), d.order...), func(c string) []string {
+		var list []string
+		for p := range d.prev[c] {
+			list = append(list, p)
+		}
+		return list
+	})
+}
+
+// Position returns the rank of course in the current order, starting at 1,
+// or 0 if the course is unknown.
+func (d *dynamicOrder) Position(course string) int {
+	p, ok := d.pos[course]
+	if !ok {
+		return 0
+	}
+	return p + 1
+}
+
+func (d *dynamicOrder) String() string {
+	var b strings.Builder
+	d.Order().WriteText(&b)
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	passingGrade      = 60 // lowest grade that counts a course as completed
+	maxCoursesPerTerm = 4  // size of the recommended next-term set
+)
+
+// studentRecord is what a student already did. A graded course counts as
+// completed only with at least passingGrade, whether it is listed or not.
+type studentRecord struct {
+	Completed []string           `json:"completed"`
+	Grades    map[string]float64 `json:"grades,omitempty"`
+}
+
+// passed returns the set of courses the record counts as completed.
+func (r studentRecord) passed() map[string]bool {
+	done := make(map[string]bool)
+	for _, c := range r.Completed {
+		if g, ok := r.Grades[c]; ok && g < passingGrade {
+			continue
+		}
+		done[c] = true
+	}
+	for c, g := range r.Grades {
+		if g >= passingGrade {
+			done[c] = true
+		}
+	}
+	return done
+}
+
+// eligibility answers "what can I take next".
+type eligibility struct {
+	Available   []string            `json:"available"`
+	Blocked     map[string][]string `json:"blocked"` // course -> missing prerequisites
+	Recommended []string            `json:"recommended"`
+}
+
+// eligible splits the courses of m the student has not completed into the
+// ones available now and the blocked ones, and recommends up to
+// maxCoursesPerTerm available courses, preferring those that most other
+// courses depend on, directly or transitively.
+func eligible(m map[string]map[string]bool, r studentRecord) eligibility {
+	done := r.passed()
+	result := eligibility{Blocked: make(map[string][]string)}
+	for _, c := range graphNodes(m) {
+		if done[c] {
+			continue
+		}
+		var missing []string
+		for d := range m[c] {
+			if !done[d] {
+				missing = append(missing, d)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			result.Blocked[c] = missing
+			continue
+		}
+		result.Available = append(result.Available, c)
+	}
+
+	neededBy := neededByIndex(m)
+	unlocks := make(map[string]int)
+	for _, c := range result.Available {
+		unlocks[c] = len(dependentsOf(neededBy, c))
+	}
+	result.Recommended = append([]string(nil), result.Available...)
+	sort.SliceStable(result.Recommended, func(i, j int) bool {
+		return unlocks[result.Recommended[i]] > unlocks[result.Recommended[j]]
+	})
+	if len(result.Recommended) > maxCoursesPerTerm {
+		result.Recommended = result.Recommended[:maxCoursesPerTerm]
+	}
+	return result
+}
+
+// neededByIndex maps each course of m to the courses that list it as a
+// prerequisite.
+func neededByIndex(m map[string]map[string]bool) map[string][]string {
+	neededBy := make(map[string][]string)
+	for course, deps := range m {
+		for d := range deps {
+			neededBy[d] = append(neededBy[d], course)
+		}
+	}
+	return neededBy
+}
+
+// dependentsOf returns every course that requires c, directly or
+// transitively, given the index from neededByIndex.
+func dependentsOf(neededBy map[string][]string, c string) map[string]bool {
+	seen := make(map[string]bool)
+	worklist := []string{c}
+	for len(worklist) > 0 {
+		item := worklist[0]
+		worklist = worklist[1:]
+		for _, next := range neededBy[item] {
+			if !seen[next] {
+				seen[next] = true
+				worklist = append(worklist, next)
+			}
+		}
+	}
+	return seen
+}
+
+// eligibilityHandler serves eligible over HTTP. A POST takes a JSON
+// studentRecord; a GET takes repeated completed=COURSE parameters and
+// grade=COURSE:GRADE parameters.
+func eligibilityHandler(m map[string]map[string]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var record studentRecord
+		switch req.Method {
+		case http.MethodPost:
+			if err := json.NewDecoder(req.Body).Decode(&record); err != nil {
+				http.Error(w, "bad student record: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodGet:
+			q := req.URL.Query()
+			record.Completed = q["completed"]
+			for _, g := range q["grade"] {
+				i := strings.LastIndex(g, ":")
+				if i < 0 {
+					http.Error(w, "bad grade "+g+", want COURSE:GRADE", http.StatusBadRequest)
+					return
+				}
+				grade, err := strconv.ParseFloat(g[i+1:], 64)
+				if err != nil {
+					http.Error(w, "bad grade "+g+": "+err.Error(), http.StatusBadRequest)
+					return
+				}
+				if record.Grades == nil {
+					record.Grades = make(map[string]float64)
+				}
+				record.Grades[g[:i]] = grade
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(eligible(m, record))
+	}
+}
@@ -1,13 +1,14 @@
 package main
 
 import (
-	"container/list"
+	"context"
 	"fmt"
 	"golang.org/x/net/html"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -15,6 +16,8 @@ import (
 	"unicode"
 	"unicode/utf8"
 	"math"
+	"math/rand"
+	"time"
 )
 
 // Ex4.3 : Reverse an array using pointer and without slice.
@@ -136,50 +139,18 @@ var regularPrereqs = map[string][]string{
 	"programming languages": {"data structures", "computer organization"},
 }
 
-func cycleTopoSort(m map[string][]string) []string {
-	var order []string
-	seen := make(map[string]bool)
-	current := list.New()
-	var visitAll func(items []string)
-	visitAll = func(items []string) {
-		for _, item := range items {
-			current.PushFront(item)
-			checkCycle(item, current)
-			if !seen[item] {
-				seen[item] = true
-				visitAll(m[item])
-				order = append(order, item)
-			}
-			current.Init()
-		}
-	}
+func cycleTopoSort(m map[string][]string) Ordering {
 	var keys []string
 	for key := range m {
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
-	visitAll(keys)
-	return order
-}
-
-func checkCycle(item string, current *list.List) {
-	cycle := "cycle: "
-	if current.Len() < 2 {
-		return
-	}
-	for e := current.Front().Next(); e != nil; e = e.Next() {
-		if e.Value.(string) == item {
-			for current.Back().Value.(string) != item {
-				current.Remove(current.Back())
-			}
-			for a := current.Back(); a.Prev() != nil; a = a.Prev() {
-				cycle += a.Value.(string) + " -> "
-			}
-			fmt.Println(cycle + current.Back().Value.(string))
-			current.Init()
-			return
-		}
+	g := internLists(m, keys)
+	roots := g.allIDs()[:len(keys)]
+	printCycle := func(path []int32) {
+		fmt.Println("cycle: " + strings.Join(g.namesOf(path), " -> "))
 	}
+	return orderingOfLists(g.namesOf(g.postorder(roots, printCycle)), m)
 }
 
 // prereqs maps computer science courses to their prerequisites.
@@ -200,52 +171,19 @@ var prereqs = map[string]map[string]bool{
 	"programming languages": {"data structures": true, "computer organization": true},
 }
 
-func topoSort(m map[string]map[string]bool) map[int]string {
-	rank := 1
-	mapOrder := make(map[int]string)
-	seen := make(map[string]bool)
-	var visitAll func(items map[string]bool)
-	visitAll = func(items map[string]bool) {
-		for item := range items {
-			if !seen[item] {
-				seen[item] = true
-				visitAll(m[item])
-				mapOrder[rank] = item
-				rank++
-			}
-		}
-	}
-	for k := range m {
-		visitAll(m[k])
-		if !seen[k] {
-			mapOrder[rank] = k
-			seen[k] = true
-			rank++
-		}
-	}
-	return mapOrder
+func topoSort(m map[string]map[string]bool) Ordering {
+	g := internPrereqs(m)
+	return orderingOf(g.namesOf(g.postorder(g.allIDs(), nil)), m)
 }
 
-func printInOrder(rankToCourse map[int]string) {
-	var len = len(rankToCourse)
-	for i := 1; i < len+1; i++ {
-		fmt.Printf("%d:\t%s\n", i, rankToCourse[i])
-	}
+func printInOrder(order Ordering) {
+	order.WriteText(os.Stdout)
 }
 
-func isValid(result map[int]string) interface{} {
-	var getRank func(subject string) int
-	getRank = func(subject string) int {
-		for k, v := range result {
-			if v == subject {
-				return k
-			}
-		}
-		return -1
-	}
+func isValid(result Ordering) bool {
 	for course, prerequesites := range prereqs {
 		for subject := range prerequesites {
-			if getRank(subject) > getRank(course) {
+			if result.Rank(subject) > result.Rank(course) {
 				return false
 			}
 		}
@@ -254,39 +192,35 @@ func isValid(result map[int]string) interface{} {
 }
 
 func printCycleTopologicalSort(strings map[string][]string) {
-	for i, course := range cycleTopoSort(cyclePrereqs) {
-		fmt.Printf("%d:\t%s\n", i+1, course)
-	}
+	cycleTopoSort(cyclePrereqs).WriteText(os.Stdout)
 }
 
 func outline2(url string) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		return err
-	}
+	return outlineSource(context.Background(), http.DefaultClient, os.Stdout, url)
+}
+
+// writeOutline prints the start and end tag of every element under doc,
+// indented by depth.
+func writeOutline(w io.Writer, doc *html.Node) {
 	var depth int
 
-	startElement := func(n *html.Node) {
+	startElement := func(n *html.Node) walkAction {
 		if n.Type == html.ElementNode {
-			fmt.Printf("%*s<%s>\n", depth*2, "", n.Data)
+			fmt.Fprintf(w, "%*s<%s>\n", depth*2, "", n.Data)
 			depth++
 		}
+		return walkContinue
 	}
 
-	endElement := func(n *html.Node) {
+	endElement := func(n *html.Node) walkAction {
 		if n.Type == html.ElementNode {
 			depth--
-			fmt.Printf("%*s</%s>\n", depth*2, "", n.Data)
+			fmt.Fprintf(w, "%*s</%s>\n", depth*2, "", n.Data)
 		}
+		return walkContinue
 	}
 
-	forEachNode(doc, startElement, endElement)
-	return nil
+	walkNodes(doc, startElement, endElement)
 }
 
 // forEachNode calls the functions pre(x) and post(x) for each node
@@ -294,56 +228,58 @@ func outline2(url string) error {
 // pre is called before the children are visited (preorder) and
 // post is called after (postorder).
 func forEachNode(n *html.Node, pre, post func(n *html.Node)) {
-	if pre != nil {
-		pre(n)
-	}
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		forEachNode(c, pre, post)
+	walkNodes(n, always(pre), always(post))
+}
+
+// always adapts f to walkNodes, never stopping the walk.
+func always(f func(n *html.Node)) func(n *html.Node) walkAction {
+	if f == nil {
+		return nil
 	}
-	if post != nil {
-		post(n)
+	return func(n *html.Node) walkAction {
+		f(n)
+		return walkContinue
 	}
 }
 
-func callOutline(args []string) {
+func callOutline(args []string) error {
 	for _, url := range args {
-		outline2(url)
+		if err := outline2(url); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// Extract makes an HTTP GET request to the specified URL, parses
-// the response as HTML, and returns the links in the HTML document.
+// Extract makes an HTTP GET request to the specified http or https URL,
+// parses the response as HTML, and returns the links in the HTML document,
+// resolved against its base URL. Any other URL, such as a mailto: or ftp:
+// link found while crawling, is an error rather than a local file name.
 func Extract(url string) ([]string, error) {
-	resp, err := http.Get(url)
+	e, err := ExtractPage(url)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("getting %s: %s", url, resp.Status)
-	}
-	doc, err := html.Parse(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return nil, fmt.Errorf("parsing %s as HTML: %v", url, err)
-	}
+	return e.Links, nil
+}
+
+// pageLinks returns the href of every anchor in doc, resolved against the
+// document base for docURL.
+func pageLinks(doc *html.Node, docURL *neturl.URL) []string {
+	base := documentBase(doc, docURL)
 	var links []string
-	visitNode := func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, a := range n.Attr {
-				if a.Key != "href" {
-					continue
-				}
-				link, err := resp.Request.URL.Parse(a.Val)
-				if err != nil {
-					continue // ignore bad URLs
-				}
-				links = append(links, link.String())
-			}
+	for _, n := range ElementsByTagName(doc, "a") {
+		href, ok := attr(n, "href")
+		if !ok {
+			continue
 		}
+		link, err := resolve(base, href)
+		if err != nil {
+			continue // ignore bad URLs
+		}
+		links = append(links, link.String())
 	}
-	forEachNode(doc, visitNode, nil)
-	return links, nil
+	return links
 }
 
 // breadthFirst calls f for each item in the worklist.
@@ -682,6 +618,15 @@ func svg(w io.Writer) {
 
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 
 	fmt.Println("Ex4.3")
 	s2 := [5]int{1, 2, 3, 4, 5}
@@ -739,8 +684,60 @@ func main() {
 		15:	programming languages
 	*/
 
+	fmt.Println("Ex5.11 suggested removals")
+	if err := printRemovals(os.Stdout, cyclePrereqs, true); err != nil {
+		fmt.Println(err)
+	}
+
+	fmt.Println("AND/OR prerequisites")
+	terms, err := ruleCatalogExample.planTerms()
+	for i, term := range terms {
+		fmt.Printf("term %d:\t%s\n", i+1, strings.Join(term, ", "))
+	}
+	fmt.Println("the function is: ", err == nil) // physics and physics lab share a term
+	fmt.Println("feasible: ", ruleCatalogExample.isFeasible(), catalogOf(prereqs).isFeasible())
+
+	fmt.Println("What can I take next")
+	next := eligible(prereqs, studentRecord{Completed: []string{"intro to programming", "discrete math"}})
+	fmt.Println("available: ", next.Available)
+	fmt.Println("recommended: ", next.Recommended)
+
+	fmt.Println("Catalog diff")
+	diffGraphs(regularPrereqs, cyclePrereqs).writeText(os.Stdout)
+
+	fmt.Println("Dropping a course")
+	if impact, err := courseImpact(prereqs, "discrete math"); err == nil {
+		impact.writeText(os.Stdout)
+	}
+	if impact, err := courseImpact(prereqs, "discrete math",
+		prereqEdge{"data structures", "intro to programming"},
+		prereqEdge{"formal languages", "intro to programming"}); err == nil {
+		impact.writeText(os.Stdout)
+	}
+
+	fmt.Println("Task runner")
+	report, err := runTasks(context.Background(), courseTasks(prereqs, os.Stdout), 4, false)
+	printTaskReport(os.Stdout, report)
+	fmt.Println("the function is: ", err == nil) // every course taken after its prerequisites
+
+	fmt.Println("Orderings")
+	count, err := countOrderings(prereqs)
+	fmt.Println("number of valid orderings: ", count, err)
+	sample, err := randomOrdering(prereqs, rand.New(rand.NewSource(time.Now().UnixNano())))
+	fmt.Println(sample.Courses)
+	fmt.Println("the function is: ", err == nil && isOrderingOf(prereqs, sample))
+
+	fmt.Println("Incremental order")
+	dyn, err := dynamicOrderOf(prereqs)
+	if err == nil {
+		fmt.Println(dyn.AddEdge("linear algebra", "calculus")) // cycle: calculus -> linear algebra -> calculus
+		fmt.Println("the function is: ", isOrderingOf(prereqs, dyn.Order()))
+	}
+
 	fmt.Println("Ex5.12")
-	callOutline([]string{"http://gopl.io"})
+	if err := callOutline([]string{"http://gopl.io"}); err != nil {
+		fmt.Println(err)
+	}
 	/*
 		<html>
 		  <head>
@@ -898,6 +895,123 @@ func main() {
 		</html>
 	*/
 
+	fmt.Println("Pretty printer")
+	if doc, err := html.Parse(strings.NewReader(samplePage)); err == nil {
+		prettyPrint(os.Stdout, doc)
+	}
+
+	fmt.Println("CSS selectors")
+	if doc, err := html.Parse(strings.NewReader(samplePage)); err == nil {
+		found, err := QuerySelectorAll(doc, "p.intro > b, img[alt*=gopher]")
+		fmt.Println(len(found), err) // 2 <nil>
+		_, err = QuerySelectorAll(doc, "a:hover")
+		fmt.Println(err) // unsupported pseudo-class :hover
+	}
+
+	fmt.Println("XPath")
+	if doc, err := html.Parse(strings.NewReader(samplePage)); err == nil {
+		for _, expr := range []string{"count(//p/*)", "string(//img/@alt)", "//b/text()", "//*[@class='intro']/following-sibling::*[1]"} {
+			x, err := CompileXPath(expr)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			v, err := x.Evaluate(doc)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Printf("%s:\n", expr)
+			writeXPathResult(os.Stdout, v)
+		}
+		/*
+			count(//p/*):
+			3
+			string(//img/@alt):
+			a "gopher"
+			//b/text():
+			world
+			//*[@class='intro']/following-sibling::*[1]:
+			<div></div>
+		*/
+	}
+
+	fmt.Println("Structured outline")
+	if doc, err := html.Parse(strings.NewReader(samplePage)); err == nil {
+		writeOutlineFormat(os.Stdout, doc, "compact", outlineOptions{MaxDepth: 3, Exclude: tagSet("head,script")})
+		/*
+			html
+			  body
+			    p.intro
+			    div
+		*/
+	}
+
+	fmt.Println("Table of contents")
+	if doc, err := html.Parse(strings.NewReader(`<h1 id="go">Go</h1><h3 id="maps">Maps</h3><section><h2>Slices</h2></section>`)); err == nil {
+		toc := headingOutline(doc)
+		toc.writeMarkdown(os.Stdout)
+		fmt.Println(toc.Warnings)
+		/*
+			- [Go](#go)
+			  - [Maps](#maps)
+			  - Slices
+			[h3 "Maps" follows h1 "Go", skipping h2]
+		*/
+	}
+
+	fmt.Println("Page statistics")
+	if doc, err := html.Parse(strings.NewReader(samplePage)); err == nil {
+		s := statsOf(doc)
+		fmt.Println(s.Words, s.Images, s.Scripts, s.MaxDepth, s.InlineScriptBytes, s.InlineCSSBytes)
+		// 6 1 1 4 28 20
+	}
+
+	fmt.Println("Accessibility audit")
+	if doc, err := html.Parse(strings.NewReader(`<html><body><img src="logo.png"><a href="/"></a></body></html>`)); err == nil {
+		for _, f := range auditPage(doc) {
+			fmt.Println(f.Severity, f.Rule, f.Path)
+		}
+		/*
+			error html-lang html
+			error img-alt html/body/img
+			error empty-link html/body/a
+		*/
+	}
+
+	fmt.Println("Resources")
+	if doc, err := html.Parse(strings.NewReader(samplePage)); err == nil {
+		base, _ := neturl.Parse("https://example.com/go/")
+		for _, r := range filterResources(pageResources(doc, base), kindImage, kindScript) {
+			fmt.Println(r.Kind, r.URL) // image https://example.com/go/gopher.png
+		}
+	}
+
+	fmt.Println("Charsets")
+	if r, enc, err := decodeHTML(strings.NewReader("<meta charset=windows-1251><p>\xcf\xf0\xe8\xe2\xe5\xf2"), ""); err == nil {
+		if doc, err := html.Parse(r); err == nil {
+			fmt.Println(enc.Name, enc.Source, textContent(doc)) // windows-1251 document Привет
+		}
+	}
+
+	fmt.Println("Article")
+	if doc, err := html.Parse(strings.NewReader(`<title>Gophers at home - Zoo News</title><nav><a href=/>Home</a></nav>` +
+		`<div class=story><p>Gophers dig burrows, long and deep, with many exits, to stay safe.</p></div>`)); err == nil {
+		extractArticle(doc).writeText(os.Stdout)
+		/*
+			Gophers at home
+
+			Gophers dig burrows, long and deep, with many exits, to stay safe.
+		*/
+	}
+
+	fmt.Println("Metadata")
+	if doc, err := html.Parse(strings.NewReader(`<meta property=og:type content=article>` +
+		`<div itemscope itemtype=https://schema.org/Person><span itemprop=name>Gopher</span></div>`)); err == nil {
+		m := extractMetadata(doc, nil)
+		fmt.Println(m.OpenGraph["og:type"], m.Microdata[0].Type, m.Microdata[0].Properties["name"]) // [article] [https://schema.org/Person] [Gopher]
+	}
+
 	fmt.Println("Ex5.13")
 	callCrawler([]string{"https://golang.org"})
 	/*
@@ -983,5 +1097,6 @@ func main() {
 		w.Header().Set("Content-Type", "image/svg+xml")
 		svg(w) // result in file_34.svg
 	})
+	http.HandleFunc("/eligible", eligibilityHandler(prereqs))
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
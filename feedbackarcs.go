@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+// prereqEdge says that Course requires Prereq.
+type prereqEdge struct {
+	Course string `json:"course"`
+	Prereq string `json:"prereq"`
+}
+
+func (e prereqEdge) String() string {
+	return e.Course + " -> " + e.Prereq
+}
+
+// listGraph returns the sorted courses of m and its distinct edges.
+func listGraph(m map[string][]string) ([]string, []prereqEdge) {
+	seen := make(map[string]bool)
+	seenEdge := make(map[prereqEdge]bool)
+	var nodes []string
+	var edges []prereqEdge
+	add := func(n string) {
+		if !seen[n] {
+			seen[n] = true
+			nodes = append(nodes, n)
+		}
+	}
+	for course, deps := range m {
+		add(course)
+		for _, d := range deps {
+			add(d)
+			e := prereqEdge{course, d}
+			if !seenEdge[e] {
+				seenEdge[e] = true
+				edges = append(edges, e)
+			}
+		}
+	}
+	sort.Strings(nodes)
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Course != edges[j].Course {
+			return edges[i].Course < edges[j].Course
+		}
+		return edges[i].Prereq < edges[j].Prereq
+	})
+	return nodes, edges
+}
+
+// backEdges returns the edges that order breaks, i.e. whose prerequisite is
+// not placed before the course. Removing them leaves order valid.
+func backEdges(order []string, edges []prereqEdge) []prereqEdge {
+	pos := make(map[string]int)
+	for i, n := range order {
+		pos[n] = i
+	}
+	var back []prereqEdge
+	for _, e := range edges {
+		if pos[e.Prereq] >= pos[e.Course] {
+			back = append(back, e)
+		}
+	}
+	return back
+}
+
+// elsOrder orders the courses with the Eades-Lin-Smyth heuristic: courses
+// nothing depends on go to the end, courses without prerequisites go to the
+// front, and when neither exists the course with the most dependents
+// relative to its prerequisites is placed next.
+func elsOrder(nodes []string, edges []prereqEdge) []string {
+	needs := make(map[string]map[string]bool)    // course -> prerequisites
+	neededBy := make(map[string]map[string]bool) // prerequisite -> courses
+	remaining := make(map[string]bool)
+	for _, n := range nodes {
+		needs[n] = make(map[string]bool)
+		neededBy[n] = make(map[string]bool)
+		remaining[n] = true
+	}
+	for _, e := range edges {
+		if e.Course != e.Prereq {
+			needs[e.Course][e.Prereq] = true
+			neededBy[e.Prereq][e.Course] = true
+		}
+	}
+	remove := func(n string) {
+		delete(remaining, n)
+		for p := range needs[n] {
+			delete(neededBy[p], n)
+		}
+		for c := range neededBy[n] {
+			delete(needs[c], n)
+		}
+	}
+
+	var front, back []string
+	for len(remaining) > 0 {
+		for changed := true; changed; {
+			changed = false
+			for _, n := range nodes {
+				if remaining[n] && len(neededBy[n]) == 0 {
+					back = append(back, n)
+					remove(n)
+					changed = true
+				}
+			}
+			for _, n := range nodes {
+				if remaining[n] && len(needs[n]) == 0 {
+					front = append(front, n)
+					remove(n)
+					changed = true
+				}
+			}
+		}
+		best, bestDelta := "", 0
+		for _, n := range nodes {
+			if !remaining[n] {
+				continue
+			}
+			delta := len(neededBy[n]) - len(needs[n])
+			if best == "" || delta > bestDelta {
+				best, bestDelta = n, delta
+			}
+		}
+		if best != "" {
+			front = append(front, best)
+			remove(best)
+		}
+	}
+	for i := len(back) - 1; i >= 0; i-- {
+		front = append(front, back[i])
+	}
+	return front
+}
+
+// exactOrder finds an ordering that breaks the fewest edges by dynamic
+// programming over subsets of courses, so it is limited to small graphs.
+func exactOrder(nodes []string, edges []prereqEdge) ([]string, error) {
+	n := len(nodes)
+	if n > maxExactNodes {
+		return nil, fmt.Errorf("graph has %d courses, exact mode supports at most %d", n, maxExactNodes)
+	}
+	index := make(map[string]int)
+	for i, c := range nodes {
+		index[c] = i
+	}
+	neededBy := make([]uint32, n)
+	for _, e := range edges {
+		if e.Course != e.Prereq {
+			neededBy[index[e.Prereq]] |= 1 << uint(index[e.Course])
+		}
+	}
+	full := uint32(1)<<uint(n) - 1
+	cost := make([]int32, full+1)
+	last := make([]int8, full+1)
+	for i := range cost {
+		cost[i] = -1
+	}
+	cost[0] = 0
+	for mask := uint32(0); mask < full; mask++ {
+		if cost[mask] < 0 {
+			continue
+		}
+		for v := 0; v < n; v++ {
+			bit := uint32(1) << uint(v)
+			if mask&bit != 0 {
+				continue
+			}
+			// Placing v now breaks every edge from a course already placed.
+			c := cost[mask] + int32(bits.OnesCount32(neededBy[v]&mask))
+			if cost[mask|bit] < 0 || c < cost[mask|bit] {
+				cost[mask|bit] = c
+				last[mask|bit] = int8(v)
+			}
+		}
+	}
+	order := make([]string, n)
+	for mask, i := full, n-1; i >= 0; i-- {
+		v := last[mask]
+		order[i] = nodes[v]
+		mask &^= 1 << uint(v)
+	}
+	return order, nil
+}
+
+// suggestRemovals proposes a small set of prerequisite edges whose removal
+// makes m acyclic, together with the ordering that results; levels in the
+// ordering ignore the removed edges. With exact set the set is minimum;
+// otherwise the Eades-Lin-Smyth heuristic is used.
+func suggestRemovals(m map[string][]string, exact bool) ([]prereqEdge, Ordering, error) {
+	nodes, edges := listGraph(m)
+	if !exact {
+		order := elsOrder(nodes, edges)
+		return backEdges(order, edges), orderingOfLists(order, m), nil
+	}
+	order, err := exactOrder(nodes, edges)
+	if err != nil {
+		return nil, Ordering{}, err
+	}
+	return backEdges(order, edges), orderingOfLists(order, m), nil
+}
+
+// printRemovals writes the suggested removals and the resulting order.
+func printRemovals(w io.Writer, m map[string][]string, exact bool) error {
+	removals, order, err := suggestRemovals(m, exact)
+	if err != nil {
+		return err
+	}
+	for _, e := range removals {
+		fmt.Fprintf(w, "remove: %s\n", e)
+	}
+	order.WriteText(w)
+	return nil
+}
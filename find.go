@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// walkAction tells walkNodes how to go on after a callback.
+type walkAction int
+
+const (
+	walkContinue     walkAction = iota // keep walking
+	walkSkipChildren                   // from pre: do not visit the children of this node
+	walkStop                           // end the whole walk
+)
+
+// walkNodes is forEachNode with callbacks that can end the walk early or,
+// from pre, skip the children of a node. Either callback may be nil.
+// It reports whether the walk ran to the end.
+func walkNodes(n *html.Node, pre, post func(n *html.Node) walkAction) bool {
+	if pre != nil {
+		switch pre(n) {
+		case walkStop:
+			return false
+		case walkSkipChildren:
+			return post == nil || post(n) != walkStop
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !walkNodes(c, pre, post) {
+			return false
+		}
+	}
+	return post == nil || post(n) != walkStop
+}
+
+// findElements returns, in document order, up to limit elements under n for
+// which match returns true. The walk stops once limit is reached; a limit of
+// zero or less means no limit.
+func findElements(n *html.Node, limit int, match func(n *html.Node) bool) []*html.Node {
+	var found []*html.Node
+	walkNodes(n, func(n *html.Node) walkAction {
+		if n.Type == html.ElementNode && match(n) {
+			found = append(found, n)
+			if limit > 0 && len(found) >= limit {
+				return walkStop
+			}
+		}
+		return walkContinue
+	}, nil)
+	return found
+}
+
+// attr returns the value of the attribute key of n and whether it is set.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// ElementByID returns the first element whose id is id, or nil.
+func ElementByID(doc *html.Node, id string) *html.Node {
+	found := findElements(doc, 1, func(n *html.Node) bool {
+		v, ok := attr(n, "id")
+		return ok && v == id
+	})
+	if len(found) == 0 {
+		return nil
+	}
+	return found[0]
+}
+
+// ElementsByTagName returns all elements with one of the given names.
+func ElementsByTagName(doc *html.Node, names ...string) []*html.Node {
+	want := make(map[string]bool)
+	for _, name := range names {
+		want[name] = true
+	}
+	return findElements(doc, 0, func(n *html.Node) bool { return want[n.Data] })
+}
+
+// ElementsByClass returns all elements that have class among their classes.
+func ElementsByClass(doc *html.Node, class string) []*html.Node {
+	return findElements(doc, 0, func(n *html.Node) bool {
+		v, _ := attr(n, "class")
+		for _, c := range strings.Fields(v) {
+			if c == class {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// ElementsByAttr returns all elements whose attribute key equals value.
+func ElementsByAttr(doc *html.Node, key, value string) []*html.Node {
+	return findElements(doc, 0, func(n *html.Node) bool {
+		v, ok := attr(n, key)
+		return ok && v == value
+	})
+}
+
+// textContent returns the text under n with runs of white space collapsed,
+// leaving out scripts and styles.
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	walkNodes(n, func(c *html.Node) walkAction {
+		switch {
+		case c.Type == html.ElementNode && (c.Data == "script" || c.Data == "style"):
+			return walkSkipChildren
+		case c.Type == html.TextNode:
+			b.WriteString(c.Data)
+		}
+		return walkContinue
+	}, nil)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// tocEntry is a heading in a table of contents, or a section or article
+// titled by the first heading inside it.
+type tocEntry struct {
+	Level    int         `json:"level"` // 1 to 6, from the heading
+	Tag      string      `json:"tag"`
+	ID       string      `json:"id,omitempty"` // the anchor to link to
+	Text     string      `json:"text"`
+	Children []*tocEntry `json:"children,omitempty"`
+}
+
+// tableOfContents is the semantic outline of a page.
+type tableOfContents struct {
+	Entries  []*tocEntry `json:"entries"`
+	Warnings []string    `json:"warnings,omitempty"`
+}
+
+// headingLevel returns 1 to 6 for h1 to h6 and 0 for any other node.
+func headingLevel(n *html.Node) int {
+	if n.Type == html.ElementNode && len(n.Data) == 2 && n.Data[0] == 'h' && '1' <= n.Data[1] && n.Data[1] <= '6' {
+		return int(n.Data[1] - '0')
+	}
+	return 0
+}
+
+// headingAnchor returns the id of heading, or of an anchor inside it.
+func headingAnchor(heading *html.Node) string {
+	if id, ok := attr(heading, "id"); ok {
+		return id
+	}
+	for _, a := range ElementsByTagName(heading, "a") {
+		if id, ok := attr(a, "id"); ok {
+			return id
+		}
+		if name, ok := attr(a, "name"); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// headingProblems returns what is wrong with the level of each heading of
+// doc that is out of order: a first heading that is not an h1, or one that
+// skips a level after the heading before it. A heading inside another is
+// not counted.
+func headingProblems(doc *html.Node) map[*html.Node]string {
+	problems := make(map[*html.Node]string)
+	var previous *html.Node
+	walkNodes(doc, func(n *html.Node) walkAction {
+		level := headingLevel(n)
+		if level == 0 {
+			return walkContinue
+		}
+		switch {
+		case previous == nil && level > 1:
+			problems[n] = fmt.Sprintf("the first heading, %s %q, is not an h1", n.Data, textContent(n))
+		case previous != nil && level > headingLevel(previous)+1:
+			problems[n] = fmt.Sprintf("%s %q follows %s %q, skipping h%d",
+				n.Data, textContent(n), previous.Data, textContent(previous), headingLevel(previous)+1)
+		}
+		previous = n
+		return walkSkipChildren
+	}, nil)
+	return problems
+}
+
+// headingOutline builds the table of contents of doc. Headings nest under
+// the closest earlier heading of a higher rank; a section or article is
+// an entry of its own, at the level of its first heading. It warns about
+// skipped heading levels, more than one h1 and sections without headings.
+func headingOutline(doc *html.Node) tableOfContents {
+	var toc tableOfContents
+	problems := headingProblems(doc)
+	var h1s []string
+
+	warnf := func(format string, args ...interface{}) {
+		toc.Warnings = append(toc.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	// scan returns the entries under root. If section is not nil, the
+	// first heading titles it instead of becoming an entry.
+	var scan func(root *html.Node, section *tocEntry) []*tocEntry
+	scan = func(root *html.Node, section *tocEntry) []*tocEntry {
+		var entries, open []*tocEntry
+		place := func(e *tocEntry) {
+			for len(open) > 0 && open[len(open)-1].Level >= e.Level {
+				open = open[:len(open)-1]
+			}
+			if len(open) == 0 {
+				entries = append(entries, e)
+			} else {
+				parent := open[len(open)-1]
+				parent.Children = append(parent.Children, e)
+			}
+			open = append(open, e)
+		}
+
+		walkNodes(root, func(n *html.Node) walkAction {
+			if n == root {
+				return walkContinue
+			}
+			if level := headingLevel(n); level > 0 {
+				e := &tocEntry{Level: level, Tag: n.Data, ID: headingAnchor(n), Text: textContent(n)}
+				if p, ok := problems[n]; ok {
+					warnf("%s", p)
+				}
+				if level == 1 {
+					h1s = append(h1s, fmt.Sprintf("%q", e.Text))
+				}
+				if section != nil && section.Level == 0 {
+					section.Level, section.Text = level, e.Text
+					if section.ID == "" {
+						section.ID = e.ID
+					}
+				} else {
+					place(e)
+				}
+				return walkSkipChildren
+			}
+			if n.Type == html.ElementNode && (n.Data == "section" || n.Data == "article") {
+				s := &tocEntry{Tag: n.Data}
+				s.ID, _ = attr(n, "id")
+				s.Children = scan(n, s)
+				if s.Level == 0 {
+					warnf("%s has no heading", nodePath(n))
+					// It belongs to the enclosing section, not to the
+					// heading before it.
+					switch {
+					case section != nil:
+						s.Level = section.Level + 1
+					case len(open) > 0:
+						s.Level = open[len(open)-1].Level + 1
+					default:
+						s.Level = 1
+					}
+				}
+				place(s)
+				return walkSkipChildren
+			}
+			return walkContinue
+		}, nil)
+		return entries
+	}
+
+	toc.Entries = scan(doc, nil)
+	if len(h1s) > 1 {
+		warnf("%d h1 elements: %s", len(h1s), strings.Join(h1s, ", "))
+	}
+	return toc
+}
+
+// nodePath returns the element names from the root down to n, with the
+// position of each among its same-named siblings where that is not 1,
+// as in html/body/div[2]/p.
+func nodePath(n *html.Node) string {
+	var parts []string
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		part := n.Data
+		if n.Parent != nil {
+			same, index := 0, 0
+			for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && c.Data == n.Data {
+					same++
+					if c == n {
+						index = same
+					}
+				}
+			}
+			if same > 1 {
+				part += fmt.Sprintf("[%d]", index)
+			}
+		}
+		parts = append([]string{part}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+// writeMarkdown writes the table of contents as a nested list, linking
+// every entry that has an anchor.
+func (t tableOfContents) writeMarkdown(w io.Writer) {
+	escape := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`)
+	var write func(entries []*tocEntry, depth int)
+	write = func(entries []*tocEntry, depth int) {
+		for _, e := range entries {
+			text := escape.Replace(e.Text)
+			if text == "" {
+				text = "(untitled " + e.Tag + ")"
+			}
+			if e.ID != "" {
+				text = fmt.Sprintf("[%s](#%s)", text, e.ID)
+			}
+			fmt.Fprintf(w, "%*s- %s\n", depth*2, "", text)
+			write(e.Children, depth+1)
+		}
+	}
+	write(t.Entries, 0)
+}
+
+// writeJSON writes the table of contents and its warnings as indented JSON.
+func (t tableOfContents) writeJSON(w io.Writer) error {
+	return writeIndentedJSON(w, t)
+}
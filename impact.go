@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// impactReport describes what happens to a catalog when a course is dropped.
+type impactReport struct {
+	Removed string
+	// ByDistance[i] lists the courses that need the removed course through
+	// a chain of i+1 prerequisites; ByDistance[0] are its direct dependents.
+	ByDistance [][]string
+	// Broken lists the courses that still depend on the removed course once
+	// the replacement edges are applied.
+	Broken   []string
+	Feasible bool // no course is broken
+	Acyclic  bool // the catalog after the change has no cycles
+}
+
+// courseImpact drops removed from m. A replacement edge gives a course a
+// new prerequisite instead of the removed one; courses without one keep
+// needing a course that no longer exists. The removed course and both
+// courses of a replacement must be in the catalog, and a replacement may
+// not use the removed course.
+func courseImpact(m map[string]map[string]bool, removed string, replacements ...prereqEdge) (impactReport, error) {
+	inCatalog := make(map[string]bool)
+	for _, c := range graphNodes(m) {
+		inCatalog[c] = true
+	}
+	if !inCatalog[removed] {
+		return impactReport{}, fmt.Errorf("no course %s in the catalog", removed)
+	}
+	for _, e := range replacements {
+		switch {
+		case e.Course == removed || e.Prereq == removed:
+			return impactReport{}, fmt.Errorf("replacement %s uses the removed course %s", e, removed)
+		case !inCatalog[e.Course]:
+			return impactReport{}, fmt.Errorf("replacement %s: no course %s in the catalog", e, e.Course)
+		case !inCatalog[e.Prereq]:
+			return impactReport{}, fmt.Errorf("replacement %s: no course %s in the catalog", e, e.Prereq)
+		}
+	}
+
+	r := impactReport{Removed: removed}
+	r.ByDistance = dependentLayers(m, removed)
+
+	after := copyPrereqs(m)
+	delete(after, removed)
+	replaced := make(map[string]bool)
+	for _, e := range replacements {
+		after[e.Course][e.Prereq] = true // copyPrereqs has an entry for every course
+		replaced[e.Course] = true
+	}
+	for course, deps := range after {
+		if replaced[course] {
+			delete(deps, removed)
+		}
+	}
+	r.Broken = flattenLayers(dependentLayers(after, removed))
+	for course := range after {
+		delete(after[course], removed)
+	}
+	r.Feasible = len(r.Broken) == 0
+	r.Acyclic = isAcyclic(after)
+	return r, nil
+}
+
+// dependentLayers groups the courses that require course, directly or
+// transitively, by their shortest distance from it.
+func dependentLayers(m map[string]map[string]bool, course string) [][]string {
+	neededBy := neededByIndex(m)
+	seen := map[string]bool{course: true}
+	var layers [][]string
+	for layer := []string{course}; ; {
+		var next []string
+		for _, item := range layer {
+			for _, c := range neededBy[item] {
+				if !seen[c] {
+					seen[c] = true
+					next = append(next, c)
+				}
+			}
+		}
+		if len(next) == 0 {
+			return layers
+		}
+		sort.Strings(next)
+		layers = append(layers, next)
+		layer = next
+	}
+}
+
+func flattenLayers(layers [][]string) []string {
+	var all []string
+	for _, l := range layers {
+		all = append(all, l...)
+	}
+	sort.Strings(all)
+	return all
+}
+
+// writeText prints the report one distance per line.
+func (r impactReport) writeText(w io.Writer) {
+	fmt.Fprintf(w, "dropping %s\n", r.Removed)
+	for i, layer := range r.ByDistance {
+		fmt.Fprintf(w, "  distance %d:\t%s\n", i+1, strings.Join(layer, ", "))
+	}
+	if len(r.Broken) > 0 {
+		fmt.Fprintf(w, "  still broken:\t%s\n", strings.Join(r.Broken, ", "))
+	}
+	fmt.Fprintf(w, "  feasible: %v, acyclic: %v\n", r.Feasible, r.Acyclic)
+}
+
+func copyPrereqs(m map[string]map[string]bool) map[string]map[string]bool {
+	c := make(map[string]map[string]bool)
+	for _, n := range graphNodes(m) {
+		c[n] = make(map[string]bool)
+		for d := range m[n] {
+			c[n][d] = true
+		}
+	}
+	return c
+}
+
+// isAcyclic checks m with Kahn's algorithm. It shares no code with the
+// sorts, so the property tests also use it as an oracle.
+func isAcyclic(m map[string]map[string]bool) bool {
+	missing := make(map[string]int)
+	neededBy := make(map[string][]string)
+	for _, n := range graphNodes(m) {
+		missing[n] = len(m[n])
+		for d := range m[n] {
+			neededBy[d] = append(neededBy[d], n)
+		}
+	}
+	var ready []string
+	for n, k := range missing {
+		if k == 0 {
+			ready = append(ready, n)
+		}
+	}
+	taken := 0
+	for len(ready) > 0 {
+		n := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		taken++
+		for _, c := range neededBy[n] {
+			missing[c]--
+			if missing[c] == 0 {
+				ready = append(ready, c)
+			}
+		}
+	}
+	return taken == len(missing)
+}
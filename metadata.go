@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// pageMetadata is the structured metadata of a page.
+type pageMetadata struct {
+	URL         string `json:"url,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Canonical   string `json:"canonical,omitempty"`
+	// OpenGraph holds the og: properties, and those of the article:,
+	// book:, profile:, music: and video: types, by their full names.
+	// A property may repeat, as og:image does for several images.
+	OpenGraph map[string][]string `json:"openGraph,omitempty"`
+	Twitter   map[string]string   `json:"twitter,omitempty"` // twitter: card properties by their full names
+	JSONLD    []interface{}       `json:"jsonLd,omitempty"`  // each JSON-LD block as decoded by encoding/json
+	Microdata []*microdataItem    `json:"microdata,omitempty"`
+	Errors    []string            `json:"errors,omitempty"` // JSON-LD blocks that did not parse
+}
+
+// microdataItem is an item of HTML microdata, such as a schema.org Person.
+type microdataItem struct {
+	Type []string `json:"type,omitempty"`
+	ID   string   `json:"id,omitempty"`
+	// Properties maps each name to its values in document order. A value
+	// is a string or, for a nested item, a *microdataItem.
+	Properties map[string][]interface{} `json:"properties"`
+}
+
+// openGraphPrefixes are the property prefixes of OpenGraph and its types.
+var openGraphPrefixes = []string{"og:", "article:", "book:", "profile:", "music:", "video:"}
+
+// extractMetadata collects the metadata of doc. URLs in microdata are
+// resolved against the document base for docURL, which may be nil.
+func extractMetadata(doc *html.Node, docURL *url.URL) *pageMetadata {
+	m := &pageMetadata{Canonical: canonicalURL(doc, docURL)}
+	if titles := ElementsByTagName(doc, "title"); len(titles) > 0 {
+		m.Title = textContent(titles[0])
+	}
+	for _, n := range ElementsByTagName(doc, "meta") {
+		content, ok := attr(n, "content")
+		if !ok {
+			continue
+		}
+		// Sites mix up name and property, so either is taken.
+		name, _ := attr(n, "property")
+		if name == "" {
+			name, _ = attr(n, "name")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "description":
+			if m.Description == "" {
+				m.Description = strings.TrimSpace(content)
+			}
+		case strings.HasPrefix(name, "twitter:"):
+			if m.Twitter == nil {
+				m.Twitter = make(map[string]string)
+			}
+			if _, ok := m.Twitter[name]; !ok {
+				m.Twitter[name] = content
+			}
+		default:
+			for _, prefix := range openGraphPrefixes {
+				if strings.HasPrefix(name, prefix) {
+					if m.OpenGraph == nil {
+						m.OpenGraph = make(map[string][]string)
+					}
+					m.OpenGraph[name] = append(m.OpenGraph[name], content)
+					break
+				}
+			}
+		}
+	}
+
+	blocks := 0
+	for _, n := range ElementsByTagName(doc, "script") {
+		if t, _ := attr(n, "type"); !strings.EqualFold(strings.TrimSpace(t), "application/ld+json") {
+			continue
+		}
+		blocks++
+		var v interface{}
+		if err := json.Unmarshal([]byte(preText(n)), &v); err != nil {
+			m.Errors = append(m.Errors, fmt.Sprintf("JSON-LD block %d at %s: %v", blocks, nodePath(n), err))
+			continue
+		}
+		m.JSONLD = append(m.JSONLD, v)
+	}
+
+	m.Microdata = microdata(doc, documentBase(doc, docURL))
+	return m
+}
+
+// microdata returns the top-level microdata items in doc, those with
+// itemscope that are not themselves the itemprop of another item.
+func microdata(doc *html.Node, base *url.URL) []*microdataItem {
+	var items []*microdataItem
+	forEachNode(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		_, scope := attr(n, "itemscope")
+		_, prop := attr(n, "itemprop")
+		if scope && !prop {
+			items = append(items, microdataOf(doc, n, base, map[*html.Node]bool{}))
+		}
+	}, nil)
+	return items
+}
+
+// microdataOf returns the item whose root is n. As in the HTML spec, its
+// properties are found among the descendants of n and of the elements
+// named by its itemref, except those inside nested items. visiting holds
+// the items being built, so that an itemref cycle ends.
+func microdataOf(doc, n *html.Node, base *url.URL, visiting map[*html.Node]bool) *microdataItem {
+	visiting[n] = true
+	defer delete(visiting, n)
+
+	item := &microdataItem{Properties: make(map[string][]interface{})}
+	if t, ok := attr(n, "itemtype"); ok {
+		item.Type = strings.Fields(t)
+	}
+	if id, ok := attr(n, "itemid"); ok {
+		item.ID = microdataURL(base, id)
+	}
+
+	roots := []*html.Node{n}
+	if refs, ok := attr(n, "itemref"); ok {
+		for _, id := range strings.Fields(refs) {
+			if r := ElementByID(doc, id); r != nil && r != n {
+				roots = append(roots, r)
+			}
+		}
+	}
+	seen := make(map[*html.Node]bool)
+	var crawl func(c *html.Node)
+	crawl = func(c *html.Node) {
+		if c.Type != html.ElementNode || seen[c] {
+			return
+		}
+		seen[c] = true
+		_, scope := attr(c, "itemscope")
+		if names, ok := attr(c, "itemprop"); ok {
+			var v interface{}
+			switch {
+			case !scope:
+				v = microdataValue(c, base)
+			case visiting[c]:
+				v = "ERROR" // an item cannot contain itself
+			default:
+				v = microdataOf(doc, c, base, visiting)
+			}
+			for _, name := range strings.Fields(names) {
+				item.Properties[name] = append(item.Properties[name], v)
+			}
+		}
+		if scope {
+			return // the properties below belong to the nested item
+		}
+		for gc := c.FirstChild; gc != nil; gc = gc.NextSibling {
+			crawl(gc)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		crawl(c)
+	}
+	for _, r := range roots[1:] {
+		crawl(r)
+	}
+	return item
+}
+
+// microdataValue returns the value of the property element n, which
+// depends on its tag as in the HTML spec.
+func microdataValue(n *html.Node, base *url.URL) string {
+	switch n.Data {
+	case "meta":
+		v, _ := attr(n, "content")
+		return v
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		v, _ := attr(n, "src")
+		return microdataURL(base, v)
+	case "a", "area", "link":
+		v, _ := attr(n, "href")
+		return microdataURL(base, v)
+	case "object":
+		v, _ := attr(n, "data")
+		return microdataURL(base, v)
+	case "data", "meter":
+		v, _ := attr(n, "value")
+		return v
+	case "time":
+		if v, ok := attr(n, "datetime"); ok {
+			return v
+		}
+	}
+	return textContent(n)
+}
+
+// microdataURL resolves ref against base, or returns it unchanged if it
+// cannot be.
+func microdataURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := resolve(base, ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// writeJSON writes the metadata as indented JSON.
+func (m *pageMetadata) writeJSON(w io.Writer) error {
+	return writeIndentedJSON(w, m)
+}
+
+// metadataPath returns where under dir the metadata of the page from src
+// is saved: host/path.meta.json for a URL, as the crawler lays out the
+// site, and name.meta.json for a file. A query is kept in the name, as in
+// host/list_page=2.meta.json, so that each page gets a file of its own.
+func metadataPath(dir, src string) string {
+	if isURL(src) {
+		if u, err := url.Parse(src); err == nil {
+			p := path.Clean("/" + u.Path) // no .. can climb out of dir
+			if strings.HasSuffix(u.Path, "/") || p == "/" {
+				p = path.Join(p, "index")
+			}
+			if u.RawQuery != "" {
+				p += "_" + fileNameQuery(u.RawQuery)
+			}
+			return filepath.Join(dir, u.Host, filepath.FromSlash(p)) + ".meta.json"
+		}
+	}
+	if src == "-" {
+		src = "stdin"
+	}
+	return filepath.Join(dir, filepath.Base(src)) + ".meta.json"
+}
+
+// maxQueryName is the longest query fileNameQuery keeps in full.
+const maxQueryName = 100
+
+// fileNameQuery makes a raw query safe to use in a file name: letters,
+// digits, '.', '-' and '=' are kept and anything else becomes '_'. A long
+// query is cut short and ends with a hash of the whole, so that different
+// queries still get different names.
+func fileNameQuery(query string) string {
+	name := []byte(query)
+	for i, c := range name {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-' || c == '=') {
+			name[i] = '_'
+		}
+	}
+	if len(name) <= maxQueryName {
+		return string(name)
+	}
+	h := fnv.New64a()
+	h.Write([]byte(query))
+	return fmt.Sprintf("%s_%016x", name[:maxQueryName-17], h.Sum64())
+}
+
+// ExtractMetadata is Extract for the structured metadata of a page.
+func ExtractMetadata(url string) (*pageMetadata, error) {
+	p, err := loadURL(url)
+	if err != nil {
+		return nil, err
+	}
+	m := extractMetadata(p.Doc, p.URL)
+	if p.URL != nil {
+		m.URL = p.URL.String()
+	}
+	return m, nil
+}
+
+// metaCommand implements
+// "meta [-crawl] [-limit n] [-timeout d] [-out dir] [URL|FILE|-]...".
+// It prints the metadata of each page as JSON or, with -out, saves it in
+// a file per page as metadataPath names it.
+func metaCommand(args []string) error {
+	fs := flag.NewFlagSet("meta", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for fetching each URL")
+	crawl := fs.Bool("crawl", false, "crawl from each URL, staying on its host")
+	limit := fs.Int("limit", 100, "pages to fetch per crawl; 0 for no limit")
+	out := fs.String("out", "", "directory to save a .meta.json file per page in")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	srcs := fs.Args()
+	if len(srcs) == 0 {
+		srcs = []string{"-"}
+	}
+	client := &http.Client{Timeout: *timeout}
+	pages := []*pageMetadata{}
+	var saveErr error
+	err := eachPage(client, srcs, *crawl, *limit, func(src string, p *page) {
+		m := extractMetadata(p.Doc, p.URL)
+		m.URL = src
+		name := src // a file's metadata is named after it, not its file: URL
+		if p.URL != nil {
+			m.URL = p.URL.String()
+			if isURL(m.URL) {
+				name = m.URL // where any redirects ended
+			}
+		}
+		if *out == "" {
+			pages = append(pages, m)
+			return
+		}
+		if saveErr == nil {
+			saveErr = saveMetadata(metadataPath(*out, name), m)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		return saveErr
+	}
+	return writeIndentedJSON(os.Stdout, pages)
+}
+
+// saveMetadata writes m to the file name, making its directory if need be.
+func saveMetadata(name string, m *pageMetadata) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := m.writeJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// maxExactNodes bounds the graphs countOrderings and randomOrdering accept:
+// they keep a table with one entry per subset of the courses, and 20! still
+// fits in a uint64.
+const maxExactNodes = 20
+
+// graphNodes returns every course of m, including courses that only appear
+// as a prerequisite, in sorted order.
+func graphNodes(m map[string]map[string]bool) []string {
+	seen := make(map[string]bool)
+	var nodes []string
+	for course, deps := range m {
+		if !seen[course] {
+			seen[course] = true
+			nodes = append(nodes, course)
+		}
+		for d := range deps {
+			if !seen[d] {
+				seen[d] = true
+				nodes = append(nodes, d)
+			}
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// forEachOrdering calls visit with every valid topological ordering of m,
+// generated by backtracking in lexicographic order. The slice passed to
+// visit is reused between calls. Enumeration stops when visit returns false.
+func forEachOrdering(m map[string]map[string]bool, visit func(order []string) bool) {
+	nodes := graphNodes(m)
+	missing := make(map[string]int) // prerequisites not yet placed
+	for _, n := range nodes {
+		missing[n] = len(m[n])
+	}
+	dependents := make(map[string][]string)
+	for course, deps := range m {
+		for d := range deps {
+			dependents[d] = append(dependents[d], course)
+		}
+	}
+	placed := make(map[string]bool)
+	order := make([]string, 0, len(nodes))
+	var search func() bool
+	search = func() bool {
+		if len(order) == len(nodes) {
+			return visit(order)
+		}
+		for _, n := range nodes {
+			if placed[n] || missing[n] > 0 {
+				continue
+			}
+			placed[n] = true
+			order = append(order, n)
+			for _, d := range dependents[n] {
+				missing[d]--
+			}
+			more := search()
+			for _, d := range dependents[n] {
+				missing[d]++
+			}
+			order = order[:len(order)-1]
+			placed[n] = false
+			if !more {
+				return false
+			}
+		}
+		return true
+	}
+	search()
+}
+
+// allOrderings returns up to limit topological orderings of m.
+// A limit of zero or less means no limit.
+func allOrderings(m map[string]map[string]bool, limit int) [][]string {
+	var result [][]string
+	forEachOrdering(m, func(order []string) bool {
+		result = append(result, append([]string(nil), order...))
+		return limit <= 0 || len(result) < limit
+	})
+	return result
+}
+
+// completions returns, for every set of already placed courses (as a bit
+// mask over nodes), the number of ways to finish a valid ordering from it.
+func completions(m map[string]map[string]bool, nodes []string) ([]uint64, []uint32, error) {
+	if len(nodes) > maxExactNodes {
+		return nil, nil, fmt.Errorf("graph has %d courses, exact counting supports at most %d", len(nodes), maxExactNodes)
+	}
+	index := make(map[string]int)
+	for i, n := range nodes {
+		index[n] = i
+	}
+	pre := make([]uint32, len(nodes))
+	for course, deps := range m {
+		for d := range deps {
+			pre[index[course]] |= 1 << uint(index[d])
+		}
+	}
+	full := uint32(1)<<uint(len(nodes)) - 1
+	rest := make([]uint64, full+1)
+	rest[full] = 1
+	for mask := int64(full) - 1; mask >= 0; mask-- {
+		var ways uint64
+		for v, p := range pre {
+			bit := uint32(1) << uint(v)
+			if uint32(mask)&bit == 0 && p&^uint32(mask) == 0 {
+				ways += rest[uint32(mask)|bit]
+			}
+		}
+		rest[mask] = ways
+	}
+	return rest, pre, nil
+}
+
+// countOrderings returns the exact number of valid topological orderings
+// of m. A graph with a cycle has none.
+func countOrderings(m map[string]map[string]bool) (uint64, error) {
+	rest, _, err := completions(m, graphNodes(m))
+	if err != nil {
+		return 0, err
+	}
+	return rest[0], nil
+}
+
+// randomOrdering draws one topological ordering of m uniformly at random
+// from all valid orderings.
+func randomOrdering(m map[string]map[string]bool, rng *rand.Rand) (Ordering, error) {
+	nodes := graphNodes(m)
+	rest, pre, err := completions(m, nodes)
+	if err != nil {
+		return Ordering{}, err
+	}
+	if rest[0] == 0 {
+		return Ordering{}, fmt.Errorf("graph has a cycle, no valid ordering")
+	}
+	var mask uint32
+	order := make([]string, 0, len(nodes))
+	for len(order) < len(nodes) {
+		pick := uint64(rng.Int63n(int64(rest[mask])))
+		for v, p := range pre {
+			bit := uint32(1) << uint(v)
+			if mask&bit != 0 || p&^mask != 0 {
+				continue
+			}
+			if pick < rest[mask|bit] {
+				mask |= bit
+				order = append(order, nodes[v])
+				break
+			}
+			pick -= rest[mask|bit]
+		}
+	}
+	return orderingOf(order, m), nil
+}
+
+// isOrderingOf reports whether order places every course of m exactly once
+// and after all of its prerequisites.
+func isOrderingOf(m map[string]map[string]bool, order Ordering) bool {
+	nodes := graphNodes(m)
+	if order.Len() != len(nodes) {
+		return false
+	}
+	for i, n := range order.Courses {
+		if order.Rank(n) != i+1 {
+			return false // listed twice
+		}
+	}
+	for _, n := range nodes {
+		if order.Rank(n) == 0 {
+			return false
+		}
+	}
+	for course, deps := range m {
+		for d := range deps {
+			if order.Rank(d) >= order.Rank(course) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// outlineNode is one element of a structured outline.
+type outlineNode struct {
+	Tag      string            `json:"tag"`
+	Attrs    map[string]string `json:"attributes,omitempty"`
+	Text     string            `json:"text,omitempty"` // the element's own text, whitespace collapsed
+	Children []*outlineNode    `json:"children,omitempty"`
+}
+
+// outlineOptions select the parts of a page that go into an outline.
+type outlineOptions struct {
+	MaxDepth int // levels kept below the top; zero means no limit
+	// Include, if not empty, lists the only tags shown. The children of a
+	// hidden element take its place.
+	Include map[string]bool
+	// Exclude lists tags dropped together with everything inside them.
+	Exclude map[string]bool
+}
+
+// tagSet parses a comma separated list of tags, as given on the command line.
+func tagSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			set[tag] = true
+		}
+	}
+	return set
+}
+
+// buildOutline returns the top-level elements of the outline of n.
+func buildOutline(n *html.Node, opts outlineOptions) []*outlineNode {
+	return outlineChildren(n, opts, 1)
+}
+
+func outlineChildren(n *html.Node, opts outlineOptions, depth int) []*outlineNode {
+	var list []*outlineNode
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || opts.Exclude[c.Data] {
+			continue
+		}
+		if len(opts.Include) > 0 && !opts.Include[c.Data] {
+			list = append(list, outlineChildren(c, opts, depth)...)
+			continue
+		}
+		o := &outlineNode{Tag: c.Data, Text: ownText(c)}
+		for _, a := range c.Attr {
+			if o.Attrs == nil {
+				o.Attrs = make(map[string]string)
+			}
+			o.Attrs[a.Key] = a.Val
+		}
+		if opts.MaxDepth == 0 || depth < opts.MaxDepth {
+			o.Children = outlineChildren(c, opts, depth+1)
+		}
+		list = append(list, o)
+	}
+	return list
+}
+
+// ownText joins the text children of n, not those of its descendants.
+func ownText(n *html.Node) string {
+	if rawTextElements[n.Data] {
+		return ""
+	}
+	var words []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			words = append(words, strings.Fields(c.Data)...)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// label returns the tag#id.class label of o.
+func (o *outlineNode) label() string {
+	var b strings.Builder
+	b.WriteString(o.Tag)
+	if id := o.Attrs["id"]; id != "" {
+		b.WriteString("#" + id)
+	}
+	for _, class := range strings.Fields(o.Attrs["class"]) {
+		b.WriteString("." + class)
+	}
+	return b.String()
+}
+
+// writeOutlineText writes the outline as writeOutline does, start and end
+// tags indented by depth.
+func writeOutlineText(w io.Writer, nodes []*outlineNode) {
+	var write func(nodes []*outlineNode, depth int)
+	write = func(nodes []*outlineNode, depth int) {
+		for _, o := range nodes {
+			fmt.Fprintf(w, "%*s<%s>\n", depth*2, "", o.Tag)
+			write(o.Children, depth+1)
+			fmt.Fprintf(w, "%*s</%s>\n", depth*2, "", o.Tag)
+		}
+	}
+	write(nodes, 0)
+}
+
+// writeOutlineCompact writes one tag#id.class label per line, indented by
+// depth, or as a nested Markdown list if markdown is set.
+func writeOutlineCompact(w io.Writer, nodes []*outlineNode, markdown bool) {
+	bullet := ""
+	if markdown {
+		bullet = "- "
+	}
+	var write func(nodes []*outlineNode, depth int)
+	write = func(nodes []*outlineNode, depth int) {
+		for _, o := range nodes {
+			fmt.Fprintf(w, "%*s%s%s\n", depth*2, "", bullet, o.label())
+			write(o.Children, depth+1)
+		}
+	}
+	write(nodes, 0)
+}
+
+func writeOutlineJSON(w io.Writer, nodes []*outlineNode) error {
+	return writeIndentedJSON(w, nodes)
+}
+
+// writeOutlineYAML writes the outline as a YAML sequence with the same
+// fields as the JSON form. Attributes are in sorted order.
+func writeOutlineYAML(w io.Writer, nodes []*outlineNode) {
+	if len(nodes) == 0 {
+		fmt.Fprintln(w, "[]")
+		return
+	}
+	var write func(nodes []*outlineNode, indent int)
+	write = func(nodes []*outlineNode, indent int) {
+		for _, o := range nodes {
+			fmt.Fprintf(w, "%*s- tag: %s\n", indent, "", yamlString(o.Tag))
+			if len(o.Attrs) > 0 {
+				fmt.Fprintf(w, "%*s  attributes:\n", indent, "")
+				for _, key := range sortedKeys(o.Attrs) {
+					fmt.Fprintf(w, "%*s    %s: %s\n", indent, "", yamlString(key), yamlString(o.Attrs[key]))
+				}
+			}
+			if o.Text != "" {
+				fmt.Fprintf(w, "%*s  text: %s\n", indent, "", yamlString(o.Text))
+			}
+			if len(o.Children) > 0 {
+				fmt.Fprintf(w, "%*s  children:\n", indent, "")
+				write(o.Children, indent+4)
+			}
+		}
+	}
+	write(nodes, 0)
+}
+
+// yamlString returns s as a plain YAML scalar if that reads back as the
+// same string, and double quoted otherwise.
+func yamlString(s string) string {
+	plain := s != "" && ('a' <= s[0] && s[0] <= 'z' || 'A' <= s[0] && s[0] <= 'Z')
+	for i := 0; i < len(s) && plain; i++ {
+		plain = isNameChar(s[i]) && s[i] < 0x80
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		plain = false
+	}
+	if plain {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
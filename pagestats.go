@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// pageStats summarises the content of one page, or of several added up.
+type pageStats struct {
+	URL               string         `json:"url,omitempty"`
+	Pages             int            `json:"pages"`
+	Words             int            `json:"words"` // in text outside scripts and styles
+	Images            int            `json:"images"`
+	Links             int            `json:"links"` // a and area elements with an href
+	Forms             int            `json:"forms"`
+	Scripts           int            `json:"scripts"`
+	Elements          map[string]int `json:"elements"`
+	MaxDepth          int            `json:"maxDepth"` // of elements; <html> is at depth 1
+	InlineScriptBytes int            `json:"inlineScriptBytes"`
+	InlineCSSBytes    int            `json:"inlineCssBytes"` // <style> contents and style attributes
+}
+
+// statsOf counts the content of doc.
+func statsOf(doc *html.Node) pageStats {
+	s := pageStats{Pages: 1, Elements: make(map[string]int)}
+	var depth int
+	var raw *html.Node // the script, style, noscript or template being walked
+
+	pre := func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			switch {
+			case raw == nil:
+				s.Words += len(strings.Fields(n.Data))
+			case raw.Data == "style":
+				s.InlineCSSBytes += len(n.Data)
+			case raw.Data == "script":
+				s.InlineScriptBytes += len(n.Data)
+			}
+		case html.ElementNode:
+			depth++
+			if depth > s.MaxDepth {
+				s.MaxDepth = depth
+			}
+			s.Elements[n.Data]++
+			if style, ok := attr(n, "style"); ok {
+				s.InlineCSSBytes += len(style)
+			}
+			switch n.Data {
+			case "img":
+				s.Images++
+			case "a", "area":
+				if _, ok := attr(n, "href"); ok {
+					s.Links++
+				}
+			case "form":
+				s.Forms++
+			case "script":
+				s.Scripts++
+			}
+			switch n.Data {
+			case "script", "style", "noscript", "template":
+				// The text inside is not page content. Only inline
+				// scripts and styles count towards the byte totals.
+				if raw == nil {
+					raw = n
+				}
+			}
+		}
+	}
+	post := func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			depth--
+			if n == raw {
+				raw = nil
+			}
+		}
+	}
+
+	forEachNode(doc, pre, post)
+	return s
+}
+
+// add adds the counts of t to s. The depth is the deepest of the two.
+func (s *pageStats) add(t pageStats) {
+	s.Pages += t.Pages
+	s.Words += t.Words
+	s.Images += t.Images
+	s.Links += t.Links
+	s.Forms += t.Forms
+	s.Scripts += t.Scripts
+	if s.Elements == nil {
+		s.Elements = make(map[string]int)
+	}
+	for tag, k := range t.Elements {
+		s.Elements[tag] += k
+	}
+	if t.MaxDepth > s.MaxDepth {
+		s.MaxDepth = t.MaxDepth
+	}
+	s.InlineScriptBytes += t.InlineScriptBytes
+	s.InlineCSSBytes += t.InlineCSSBytes
+}
+
+// siteStats holds the statistics of every page of a crawl and their total.
+type siteStats struct {
+	Pages []pageStats `json:"pages"`
+	Total pageStats   `json:"total"`
+}
+
+func (s *siteStats) addPage(url string, pg *page) {
+	p := statsOf(pg.Doc)
+	p.URL = url
+	s.Pages = append(s.Pages, p)
+	s.Total.add(p)
+}
+
+// writeJSON writes the statistics as indented JSON.
+func (s siteStats) writeJSON(w io.Writer) error {
+	return writeIndentedJSON(w, s)
+}
+
+// statsCommand implements "stats [-crawl] [-limit n] [-timeout d] [URL|FILE|-]...".
+// It prints the statistics of each page and their total as JSON. With
+// -crawl, every URL is crawled and each page on its host is counted.
+func statsCommand(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for fetching each URL")
+	crawl := fs.Bool("crawl", false, "crawl from each URL, staying on its host")
+	limit := fs.Int("limit", 100, "pages to fetch per crawl; 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	srcs := fs.Args()
+	if len(srcs) == 0 {
+		srcs = []string{"-"}
+	}
+	client := &http.Client{Timeout: *timeout}
+	var stats siteStats
+	if err := eachPage(client, srcs, *crawl, *limit, stats.addPage); err != nil {
+		return err
+	}
+	return stats.writeJSON(os.Stdout)
+}
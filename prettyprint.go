@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// voidElements never have content or an end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "keygen": true, "link": true,
+	"meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements hold text that must be written without escaping.
+var rawTextElements = map[string]bool{
+	"iframe": true, "noembed": true, "noframes": true, "noscript": true,
+	"plaintext": true, "script": true, "style": true, "xmp": true,
+}
+
+// preformattedElements keep their content exactly as written, so the
+// pretty printer neither indents nor trims it.
+var preformattedElements = map[string]bool{
+	"listing": true, "plaintext": true, "pre": true, "textarea": true,
+}
+
+// attrString formats the attributes of n with a leading space each.
+func attrString(n *html.Node) string {
+	var b strings.Builder
+	for _, a := range n.Attr {
+		key := a.Key
+		if a.Namespace != "" {
+			key = a.Namespace + ":" + a.Key
+		}
+		fmt.Fprintf(&b, " %s=\"%s\"", key, html.EscapeString(a.Val))
+	}
+	return b.String()
+}
+
+// prettyPrint writes the tree rooted at doc as indented HTML: one node per
+// line, text trimmed, whitespace-only text skipped, void elements as <br/>
+// and empty elements as <p></p>. Preformatted elements are written as they
+// are, and nothing follows a <plaintext> element, which has no end tag.
+func prettyPrint(w io.Writer, doc *html.Node) {
+	var depth int
+
+	start := func(n *html.Node) walkAction {
+		indent := depth * 2
+		switch n.Type {
+		case html.DoctypeNode:
+			fmt.Fprintf(w, "%*s<!DOCTYPE %s%s>\n", indent, "", n.Data, doctypeIDs(n))
+		case html.CommentNode:
+			fmt.Fprintf(w, "%*s<!--%s-->\n", indent, "", n.Data)
+		case html.TextNode:
+			text := strings.TrimSpace(n.Data)
+			if text == "" {
+				break
+			}
+			if n.Parent == nil || !rawTextElements[n.Parent.Data] {
+				text = html.EscapeString(text)
+			}
+			fmt.Fprintf(w, "%*s%s\n", indent, "", text)
+		case html.ElementNode:
+			switch {
+			case preformattedElements[n.Data] && n.Namespace == "":
+				fmt.Fprintf(w, "%*s", indent, "")
+				html.Render(w, n) // keeps the content, and the newline a parser drops after <pre>
+				if n.Data == "plaintext" {
+					return walkStop
+				}
+				fmt.Fprintln(w)
+				return walkSkipChildren
+			case voidElements[n.Data]:
+				fmt.Fprintf(w, "%*s<%s%s/>\n", indent, "", n.Data, attrString(n))
+			case n.FirstChild == nil:
+				fmt.Fprintf(w, "%*s<%s%s></%s>\n", indent, "", n.Data, attrString(n), n.Data)
+			default:
+				fmt.Fprintf(w, "%*s<%s%s>\n", indent, "", n.Data, attrString(n))
+				depth++
+			}
+		}
+		return walkContinue
+	}
+
+	end := func(n *html.Node) walkAction {
+		if n.Type == html.ElementNode && !voidElements[n.Data] && n.FirstChild != nil &&
+			!(preformattedElements[n.Data] && n.Namespace == "") {
+			depth--
+			fmt.Fprintf(w, "%*s</%s>\n", depth*2, "", n.Data)
+		}
+		return walkContinue
+	}
+
+	walkNodes(doc, start, end)
+}
+
+// doctypeIDs formats the public and system identifiers of a doctype as
+// in <!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "...">.
+func doctypeIDs(n *html.Node) string {
+	var public, system string
+	var hasPublic, hasSystem bool
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "public":
+			public, hasPublic = a.Val, true
+		case "system":
+			system, hasSystem = a.Val, true
+		}
+	}
+	switch {
+	case hasPublic && hasSystem:
+		return " PUBLIC " + doctypeQuote(public) + " " + doctypeQuote(system)
+	case hasPublic:
+		return " PUBLIC " + doctypeQuote(public)
+	case hasSystem:
+		return " SYSTEM " + doctypeQuote(system)
+	}
+	return ""
+}
+
+// doctypeQuote quotes a doctype identifier, which cannot be escaped, with
+// single quotes if it holds a double quote.
+func doctypeQuote(id string) string {
+	if strings.Contains(id, `"`) {
+		return "'" + id + "'"
+	}
+	return `"` + id + `"`
+}
+
+// checkRoundTrip pretty prints doc, parses the result and reports the first
+// difference from doc, if any.
+func checkRoundTrip(doc *html.Node) error {
+	var buf bytes.Buffer
+	prettyPrint(&buf, doc)
+	again, err := html.Parse(&buf)
+	if err != nil {
+		return err
+	}
+	return sameTree(doc, again, "")
+}
+
+// sameTree compares two trees, ignoring whitespace-only text nodes and the
+// whitespace around text, except inside preformatted elements, where text
+// must match exactly. path names the nodes compared so far.
+func sameTree(a, b *html.Node, path string) error {
+	if a.Type == html.ElementNode {
+		path += "/" + a.Data
+	}
+	if a.Type != b.Type || (a.Data != b.Data && a.Type != html.TextNode) {
+		return fmt.Errorf("%s: %s differs from %s", path, nodeLabel(a), nodeLabel(b))
+	}
+	if a.Type == html.TextNode && inPreformatted(a) && a.Data != b.Data {
+		return fmt.Errorf("%s: text %q differs from %q", path, a.Data, b.Data)
+	}
+	if a.Type == html.TextNode && strings.TrimSpace(a.Data) != strings.TrimSpace(b.Data) {
+		return fmt.Errorf("%s: text %q differs from %q", path, a.Data, b.Data)
+	}
+	if x, y := attrString(sortedAttrs(a)), attrString(sortedAttrs(b)); x != y {
+		return fmt.Errorf("%s: attributes%s differ from%s", path, x, y)
+	}
+	ac, bc := significantChildren(a), significantChildren(b)
+	if len(ac) != len(bc) {
+		return fmt.Errorf("%s: %d children differ from %d", path, len(ac), len(bc))
+	}
+	for i := range ac {
+		if err := sameTree(ac[i], bc[i], path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedAttrs(n *html.Node) *html.Node {
+	attrs := append([]html.Attribute(nil), n.Attr...)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].Namespace != attrs[j].Namespace {
+			return attrs[i].Namespace < attrs[j].Namespace
+		}
+		return attrs[i].Key < attrs[j].Key
+	})
+	return &html.Node{Attr: attrs}
+}
+
+func significantChildren(n *html.Node) []*html.Node {
+	var list []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode && strings.TrimSpace(c.Data) == "" && !inPreformatted(c) {
+			continue
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+// inPreformatted reports whether n is inside a preformatted element.
+func inPreformatted(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && preformattedElements[p.Data] && p.Namespace == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeLabel(n *html.Node) string {
+	switch n.Type {
+	case html.ElementNode:
+		return "<" + n.Data + ">"
+	case html.TextNode:
+		return "text"
+	case html.CommentNode:
+		return "comment"
+	case html.DoctypeNode:
+		return "doctype"
+	}
+	return "document"
+}
+
+// samplePage exercises the pretty printer: void and empty elements,
+// comments, raw text and attributes that need escaping.
+const samplePage = `<!DOCTYPE html>
+<html lang="en"><head><meta charset="utf-8"><title>Go &amp; HTML</title>
+<style>p > a { color: red }</style></head>
+<body><!-- navigation --><p class="intro">Hello, <b>world</b>!<br>
+<img src="gopher.png" alt="a &quot;gopher&quot;"></p><div></div>
+<script>if (a < b && c > d) { go() }</script></body></html>`
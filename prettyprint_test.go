@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// roundTripPages must parse back into the same tree once pretty printed.
+var roundTripPages = map[string]string{
+	"sample": samplePage,
+	"xhtml doctype": `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" ` +
+		`"http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd"><html><body><p>x</p></body></html>`,
+	"system doctype": `<!DOCTYPE html SYSTEM "about:legacy-compat"><p>x</p>`,
+	"pre":            "<div><pre>\n\n  indented\n\tand <b>bold</b>  \n</pre></div>",
+	"textarea":       "<form><textarea>\n  a &lt; b\n  </textarea></form>",
+	"listing":        "<listing>\n\nx  y</listing>",
+	"plaintext":      "<p>before</p><plaintext>  <b>not a tag</b> &amp;\n  </body></html>",
+	"tables":         `<table><tr><td>a<td>b</table><svg><path d="M0"/></svg><p>a<i>b</i>c</p><a href="?a=1&b=2">x</a>`,
+}
+
+func TestPrettyPrintRoundTrip(t *testing.T) {
+	for name, page := range roundTripPages {
+		doc, err := html.Parse(strings.NewReader(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkRoundTrip(doc); err != nil {
+			var buf bytes.Buffer
+			prettyPrint(&buf, doc)
+			t.Errorf("%s: %v\n%s", name, err, buf.String())
+		}
+	}
+}
+
+func TestPrettyPrintPreformatted(t *testing.T) {
+	// The parser drops the first newline after <pre>, so the printer
+	// writes the second one twice.
+	doc, err := html.Parse(strings.NewReader("<div><pre>\n\n  a\n b</pre></div>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	prettyPrint(&buf, doc)
+	if want := "      <pre>\n\n  a\n b</pre>\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("got\n%s\nwant it to contain\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// genDAG returns n courses in a random hidden order where every course
+// requires each course before it with probability density.
+func genDAG(rng *rand.Rand, n int, density float64) map[string]map[string]bool {
+	m := make(map[string]map[string]bool)
+	perm := rng.Perm(n)
+	for _, p := range perm {
+		m["c"+strconv.Itoa(p)] = make(map[string]bool)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if rng.Float64() < density {
+				m["c"+strconv.Itoa(perm[j])]["c"+strconv.Itoa(perm[i])] = true
+			}
+		}
+	}
+	return m
+}
+
+// genCyclic returns a random DAG with a cycle of length courses planted in
+// it. A length of 1 plants a course that requires itself.
+func genCyclic(rng *rand.Rand, n int, density float64, length int) map[string]map[string]bool {
+	m := genDAG(rng, n, density)
+	if length > n {
+		length = n
+	}
+	picked := rng.Perm(n)[:length]
+	for i, p := range picked {
+		next := picked[(i+1)%length]
+		m["c"+strconv.Itoa(p)]["c"+strconv.Itoa(next)] = true
+	}
+	return m
+}
+
+// genChain returns n courses where each one requires the previous one.
+func genChain(n int) map[string]map[string]bool {
+	m := map[string]map[string]bool{"c0": {}}
+	for i := 1; i < n; i++ {
+		m["c"+strconv.Itoa(i)] = map[string]bool{"c" + strconv.Itoa(i-1): true}
+	}
+	return m
+}
+
+// genFan returns one course required by n-1 others.
+func genFan(n int) map[string]map[string]bool {
+	m := map[string]map[string]bool{"c0": {}}
+	for i := 1; i < n; i++ {
+		m["c"+strconv.Itoa(i)] = map[string]bool{"c0": true}
+	}
+	return m
+}
+
+// toLists converts a prereqs style map to the cyclePrereqs style,
+// with every list sorted.
+func toLists(m map[string]map[string]bool) map[string][]string {
+	lists := make(map[string][]string)
+	for course, deps := range m {
+		list := []string{}
+		for d := range deps {
+			list = append(list, d)
+		}
+		sort.Strings(list)
+		lists[course] = list
+	}
+	return lists
+}
+
+// graphProperty must hold for every graph, cyclic or not.
+type graphProperty struct {
+	name  string
+	check func(m map[string]map[string]bool) error
+}
+
+var graphProperties = []graphProperty{
+	{"topoSort orders a DAG", func(m map[string]map[string]bool) error {
+		if isAcyclic(m) && !isOrderingOf(m, topoSort(m)) {
+			return fmt.Errorf("invalid order %v", topoSort(m).Courses)
+		}
+		return nil
+	}},
+	{"cycleTopoSort orders a DAG", func(m map[string]map[string]bool) error {
+		if !isAcyclic(m) {
+			return nil // it prints the cycles it finds
+		}
+		if o := cycleTopoSort(toLists(m)); !isOrderingOf(m, o) {
+			return fmt.Errorf("invalid order %v", o.Courses)
+		}
+		return nil
+	}},
+	{"postorder reports real cycles, and only for cyclic graphs", func(m map[string]map[string]bool) error {
+		nodes := graphNodes(m)
+		g := internLists(toLists(m), nodes)
+		var cycles [][]string
+		g.postorder(g.allIDs(), func(path []int32) {
+			cycles = append(cycles, g.namesOf(path))
+		})
+		if (len(cycles) == 0) != isAcyclic(m) {
+			return fmt.Errorf("found %d cycles", len(cycles))
+		}
+		for _, c := range cycles {
+			if len(c) < 2 || c[0] != c[len(c)-1] {
+				return fmt.Errorf("cycle %v does not end where it starts", c)
+			}
+			for i := 0; i+1 < len(c); i++ {
+				if !m[c[i]][c[i+1]] {
+					return fmt.Errorf("cycle %v uses missing edge %s -> %s", c, c[i], c[i+1])
+				}
+			}
+		}
+		return nil
+	}},
+	{"dynamicOrder rejects exactly the cyclic graphs", func(m map[string]map[string]bool) error {
+		d, err := dynamicOrderOf(m)
+		if (err == nil) != isAcyclic(m) {
+			return fmt.Errorf("dynamicOrderOf returned %v", err)
+		}
+		if err == nil && !isOrderingOf(m, d.Order()) {
+			return fmt.Errorf("invalid order %v", d.Order().Courses)
+		}
+		return nil
+	}},
+	{"diffGraphs reports new cycles exactly for cyclic graphs", func(m map[string]map[string]bool) error {
+		cycles := diffGraphs(nil, toLists(m)).NewCycles
+		if (len(cycles) == 0) != isAcyclic(m) {
+			return fmt.Errorf("found %d new cycles", len(cycles))
+		}
+		for _, c := range cycles {
+			for i := 0; i+1 < len(c); i++ {
+				if !m[c[i]][c[i+1]] {
+					return fmt.Errorf("cycle %v uses missing edge %s -> %s", c, c[i], c[i+1])
+				}
+			}
+		}
+		return nil
+	}},
+	{"diffGraphs ignores the order of prerequisites", func(m map[string]map[string]bool) error {
+		lists := toLists(m)
+		reversed := make(map[string][]string)
+		for course, deps := range lists {
+			for i := len(deps) - 1; i >= 0; i-- {
+				reversed[course] = append(reversed[course], deps[i])
+			}
+		}
+		if d := diffGraphs(lists, reversed); len(d.NewCycles) > 0 || len(d.AddedEdges) > 0 || len(d.RemovedEdges) > 0 {
+			return fmt.Errorf("reordering reported %+v", d)
+		}
+		return nil
+	}},
+	{"countOrderings and randomOrdering agree with enumeration", func(m map[string]map[string]bool) error {
+		if len(graphNodes(m)) > 8 {
+			return nil
+		}
+		count, err := countOrderings(m)
+		if err != nil {
+			return err
+		}
+		if all := allOrderings(m, 0); uint64(len(all)) != count {
+			return fmt.Errorf("counted %d orderings, enumerated %d", count, len(all))
+		}
+		if (count == 0) == isAcyclic(m) {
+			return fmt.Errorf("counted %d orderings", count)
+		}
+		if count > 0 {
+			o, err := randomOrdering(m, rand.New(rand.NewSource(int64(count))))
+			if err != nil || !isOrderingOf(m, o) {
+				return fmt.Errorf("random ordering %v: %v", o.Courses, err)
+			}
+		}
+		return nil
+	}},
+	{"suggestRemovals leaves an acyclic graph", func(m map[string]map[string]bool) error {
+		removals, order, err := suggestRemovals(toLists(m), false)
+		if err != nil {
+			return err
+		}
+		kept := copyPrereqs(m)
+		for _, e := range removals {
+			delete(kept[e.Course], e.Prereq)
+		}
+		if !isAcyclic(kept) || !isOrderingOf(kept, order) {
+			return fmt.Errorf("still cyclic after removing %v", removals)
+		}
+		if isAcyclic(m) && len(removals) > 0 {
+			return fmt.Errorf("suggested %v for a DAG", removals)
+		}
+		return nil
+	}},
+	{"suggestRemovals in exact mode removes as few edges as any order", func(m map[string]map[string]bool) error {
+		if len(graphNodes(m)) > 7 {
+			return nil
+		}
+		removals, order, err := suggestRemovals(toLists(m), true)
+		if err != nil {
+			return err
+		}
+		kept := copyPrereqs(m)
+		for _, e := range removals {
+			delete(kept[e.Course], e.Prereq)
+		}
+		if !isAcyclic(kept) || !isOrderingOf(kept, order) {
+			return fmt.Errorf("still cyclic after removing %v", removals)
+		}
+		if min := fewestBackEdges(m); len(removals) != min {
+			return fmt.Errorf("removed %d edges %v, but %d are enough", len(removals), removals, min)
+		}
+		return nil
+	}},
+}
+
+// fewestBackEdges tries every order of the courses of m and returns the
+// fewest edges any of them breaks, counting a course that requires itself
+// as always broken.
+func fewestBackEdges(m map[string]map[string]bool) int {
+	nodes := graphNodes(m)
+	_, edges := listGraph(toLists(m))
+	best := len(edges)
+	var permute func(k int)
+	permute = func(k int) {
+		if k == len(nodes) {
+			if n := len(backEdges(nodes, edges)); n < best {
+				best = n
+			}
+			return
+		}
+		for i := k; i < len(nodes); i++ {
+			nodes[k], nodes[i] = nodes[i], nodes[k]
+			permute(k + 1)
+			nodes[k], nodes[i] = nodes[i], nodes[k]
+		}
+	}
+	permute(0)
+	return best
+}
+
+// shrinkGraph removes courses and then edges from m for as long as check
+// keeps failing, and returns the smallest failing graph it found.
+func shrinkGraph(m map[string]map[string]bool, check func(map[string]map[string]bool) error) map[string]map[string]bool {
+	m = copyPrereqs(m)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range graphNodes(m) {
+			smaller := copyPrereqs(m)
+			delete(smaller, n)
+			for _, deps := range smaller {
+				delete(deps, n)
+			}
+			if check(smaller) != nil {
+				m, changed = smaller, true
+			}
+		}
+		for _, n := range graphNodes(m) {
+			for d := range m[n] {
+				smaller := copyPrereqs(m)
+				delete(smaller[n], d)
+				if check(smaller) != nil {
+					m, changed = smaller, true
+				}
+			}
+		}
+	}
+	return m
+}
+
+// TestGraphProperties checks every property against random graphs of
+// each kind, plus a long chain and a wide fan. A failing graph is shrunk
+// before it is reported.
+func TestGraphProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var graphs []map[string]map[string]bool
+	for i := 0; i < 200; i++ {
+		n := 1 + rng.Intn(12)
+		density := rng.Float64()
+		graphs = append(graphs, genDAG(rng, n, density))
+		graphs = append(graphs, genCyclic(rng, n, density/2, 1+rng.Intn(n)))
+	}
+	graphs = append(graphs, genChain(2000), genFan(2000))
+
+	for _, p := range graphProperties {
+		t.Run(p.name, func(t *testing.T) {
+			for _, g := range graphs {
+				if err := p.check(g); err != nil {
+					min := shrinkGraph(g, p.check)
+					t.Fatalf("%v\nminimal graph:\n\t%s", p.check(min), strings.Join(listEdgesOf(min), "\n\t"))
+				}
+			}
+		})
+	}
+}
+
+// TestShrinkGraph shrinks a graph against a property that fails for every
+// cyclic graph, which must leave a single cycle.
+func TestShrinkGraph(t *testing.T) {
+	acyclic := func(m map[string]map[string]bool) error {
+		if !isAcyclic(m) {
+			return fmt.Errorf("cyclic")
+		}
+		return nil
+	}
+	min := shrinkGraph(genCyclic(rand.New(rand.NewSource(2)), 10, 0.4, 4), acyclic)
+	if isAcyclic(min) {
+		t.Fatal("shrunk to an acyclic graph")
+	}
+	for _, n := range graphNodes(min) {
+		if len(min[n]) != 1 {
+			t.Fatalf("not a single cycle:\n\t%s", strings.Join(listEdgesOf(min), "\n\t"))
+		}
+	}
+}
+
+// listEdgesOf returns one line per edge of m and one per course without
+// prerequisites, sorted.
+func listEdgesOf(m map[string]map[string]bool) []string {
+	lists := toLists(m)
+	var lines []string
+	for _, n := range graphNodes(m) {
+		if len(lists[n]) == 0 {
+			lines = append(lines, n)
+		}
+		for _, d := range lists[n] {
+			lines = append(lines, prereqEdge{n, d}.String())
+		}
+	}
+	return lines
+}
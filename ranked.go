@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Ordering is a ranked list of courses as produced by the topological sorts.
+// Ranks start at 1. The level of a course is the length of the longest chain
+// of prerequisites placed before it, so courses without prerequisites are at
+// level 0.
+type Ordering struct {
+	Courses []string
+	rank    map[string]int
+	level   map[string]int
+}
+
+// newOrdering ranks courses in the given order and computes their levels
+// from prereqsOf. Prerequisites placed after a course, which only happens
+// for cyclic graphs, do not count towards its level.
+func newOrdering(courses []string, prereqsOf func(course string) []string) Ordering {
+	o := Ordering{
+		Courses: courses,
+		rank:    make(map[string]int, len(courses)),
+		level:   make(map[string]int, len(courses)),
+	}
+	for i, c := range courses {
+		o.rank[c] = i + 1
+	}
+	for _, c := range courses {
+		level := 0
+		for _, p := range prereqsOf(c) {
+			if r, ok := o.rank[p]; ok && r < o.rank[c] && o.level[p]+1 > level {
+				level = o.level[p] + 1
+			}
+		}
+		o.level[c] = level
+	}
+	return o
+}
+
+// orderingOf ranks courses of a prereqs style map.
+func orderingOf(courses []string, m map[string]map[string]bool) Ordering {
+	return newOrdering(courses, func(c string) []string {
+		var list []string
+		for d := range m[c] {
+			list = append(list, d)
+		}
+		return list
+	})
+}
+
+// orderingOfLists ranks courses of a cyclePrereqs style map.
+func orderingOfLists(courses []string, m map[string][]string) Ordering {
+	return newOrdering(courses, func(c string) []string { return m[c] })
+}
+
+// Len returns the number of ranked courses.
+func (o Ordering) Len() int {
+	return len(o.Courses)
+}
+
+// Rank returns the rank of course, or 0 if it is not in the ordering.
+func (o Ordering) Rank(course string) int {
+	return o.rank[course]
+}
+
+// Level returns the level of course, or -1 if it is not in the ordering.
+func (o Ordering) Level(course string) int {
+	l, ok := o.level[course]
+	if !ok {
+		return -1
+	}
+	return l
+}
+
+type rankedCourse struct {
+	Rank   int    `json:"rank"`
+	Course string `json:"course"`
+	Level  int    `json:"level"`
+}
+
+func (o Ordering) ranked() []rankedCourse {
+	list := make([]rankedCourse, len(o.Courses))
+	for i, c := range o.Courses {
+		list[i] = rankedCourse{i + 1, c, o.level[c]}
+	}
+	return list
+}
+
+// WriteText writes one "rank:<tab>course" line per course.
+func (o Ordering) WriteText(w io.Writer) {
+	for i, c := range o.Courses {
+		fmt.Fprintf(w, "%d:\t%s\n", i+1, c)
+	}
+}
+
+// WriteJSON writes the ordering as a JSON array of rank, course and level.
+func (o Ordering) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, o.ranked())
+}
+
+// WriteCSV writes the ordering as CSV with a rank,course,level header.
+func (o Ordering) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "course", "level"})
+	for _, r := range o.ranked() {
+		cw.Write([]string{strconv.Itoa(r.Rank), r.Course, strconv.Itoa(r.Level)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// articleBlock is one paragraph-like piece of an article.
+type articleBlock struct {
+	Kind string `json:"kind"` // the element it came from: p, h2 to h6, li, blockquote or pre
+	Text string `json:"text"`
+}
+
+// article is the main content of a page without its navigation, footers
+// and other boilerplate.
+type article struct {
+	URL    string         `json:"url,omitempty"`
+	Title  string         `json:"title"`
+	Byline string         `json:"byline,omitempty"`
+	Blocks []articleBlock `json:"blocks"`
+}
+
+// These follow the class and id hints of Mozilla's Readability.
+var (
+	unlikelyHints = regexp.MustCompile(`(?i)-ad-|ad-break|adbox|advert|banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|legends|menu|modal|nav|pager|popup|promo|related|remark|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|tweet|widget`)
+	maybeHints    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveHints = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|story|text|blog`)
+	negativeHints = regexp.MustCompile(`(?i)hidden|banner|combx|comment|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	bylineHints   = regexp.MustCompile(`(?i)byline|author|dateline|writtenby`)
+)
+
+// boilerplateTags are never part of an article.
+var boilerplateTags = map[string]bool{
+	"aside": true, "button": true, "canvas": true, "embed": true, "footer": true,
+	"form": true, "header": true, "iframe": true, "input": true, "nav": true,
+	"noscript": true, "object": true, "script": true, "select": true, "style": true,
+	"svg": true, "template": true, "textarea": true,
+}
+
+// blockTags start a block of their own when found inside a div.
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true,
+	"div": true, "dl": true, "dt": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "ul": true,
+}
+
+func hints(n *html.Node) string {
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	return class + " " + id
+}
+
+// isBoilerplate reports whether n is hidden, or by its tag, class or id
+// unlikely to hold article text.
+func isBoilerplate(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if boilerplateTags[n.Data] {
+		return true
+	}
+	if _, ok := attr(n, "hidden"); ok {
+		return true
+	}
+	if v, _ := attr(n, "aria-hidden"); v == "true" {
+		return true
+	}
+	if style, _ := attr(n, "style"); strings.Contains(strings.ReplaceAll(style, " ", ""), "display:none") {
+		return true
+	}
+	switch n.Data {
+	case "html", "body", "article", "main":
+		return false
+	}
+	h := hints(n)
+	return unlikelyHints.MatchString(h) && !maybeHints.MatchString(h)
+}
+
+// classWeight scores the class and id of n as article-like or not.
+func classWeight(n *html.Node) float64 {
+	var w float64
+	if h := hints(n); strings.TrimSpace(h) != "" {
+		if positiveHints.MatchString(h) {
+			w += 25
+		}
+		if negativeHints.MatchString(h) {
+			w -= 25
+		}
+	}
+	return w
+}
+
+// tagWeight is the starting score of a candidate by its tag.
+func tagWeight(n *html.Node) float64 {
+	switch n.Data {
+	case "div", "article", "main", "section":
+		return 5
+	case "pre", "td", "blockquote":
+		return 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		return -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		return -5
+	}
+	return 0
+}
+
+// linkDensity is the share of the text of n that is inside links.
+func linkDensity(n *html.Node) float64 {
+	text := len(textContent(n))
+	if text == 0 {
+		return 0
+	}
+	links := 0
+	for _, a := range ElementsByTagName(n, "a") {
+		links += len(textContent(a))
+	}
+	return float64(links) / float64(text)
+}
+
+// hasBlockChildren reports whether any child of n is a block element.
+func hasBlockChildren(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.Data] {
+			return true
+		}
+	}
+	return false
+}
+
+// extractArticle finds the main content of doc. Each paragraph of 25
+// characters or more scores its parent and, by half, its grandparent;
+// the best scored element after discounting links, with any siblings
+// that score nearly as well, is the article.
+func extractArticle(doc *html.Node) article {
+	a := article{Title: articleTitle(doc)}
+	var byline *html.Node
+	a.Byline, byline = articleByline(doc)
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+	credit := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = tagWeight(n) + classWeight(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+	walkNodes(doc, func(n *html.Node) walkAction {
+		if n.Type != html.ElementNode {
+			return walkContinue
+		}
+		if isBoilerplate(n) {
+			return walkSkipChildren
+		}
+		switch {
+		case n.Data == "p" || n.Data == "pre" || n.Data == "td",
+			n.Data == "div" && !hasBlockChildren(n):
+		default:
+			return walkContinue
+		}
+		text := textContent(n)
+		if len(text) < 25 {
+			return walkContinue
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text)/100), 3)
+		credit(n.Parent, score)
+		if n.Parent != nil {
+			credit(n.Parent.Parent, score/2)
+		}
+		return walkContinue
+	}, nil)
+
+	var best *html.Node
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(n)
+		if best == nil || scores[n] > scores[best] {
+			best = n
+		}
+	}
+	if best == nil {
+		if body := ElementsByTagName(doc, "body"); len(body) > 0 {
+			best = body[0]
+		} else {
+			best = doc
+		}
+	}
+
+	parts := []*html.Node{best}
+	if best.Parent != nil {
+		threshold := math.Max(10, scores[best]*0.2)
+		parts = nil
+		for s := best.Parent.FirstChild; s != nil; s = s.NextSibling {
+			if s == best {
+				parts = append(parts, s)
+				continue
+			}
+			if s.Type != html.ElementNode || isBoilerplate(s) {
+				continue
+			}
+			if score, ok := scores[s]; ok && score >= threshold {
+				parts = append(parts, s)
+			} else if s.Data == "p" {
+				text, density := textContent(s), linkDensity(s)
+				if len(text) > 80 && density < 0.25 || density == 0 && strings.HasSuffix(text, ".") {
+					parts = append(parts, s)
+				}
+			}
+		}
+	}
+	for _, p := range parts {
+		a.Blocks = append(a.Blocks, articleBlocks(p, a.Title, byline)...)
+	}
+	return a
+}
+
+// articleBlocks returns the paragraphs, headings, list items, quotes and
+// preformatted text under n, leaving out boilerplate, link lists, a heading
+// that repeats the title and the byline element, or a block that holds
+// nothing else; byline may be nil.
+func articleBlocks(n *html.Node, title string, byline *html.Node) []articleBlock {
+	var bylineText string
+	if byline != nil {
+		bylineText = textContent(byline)
+	}
+	var blocks []articleBlock
+	walkNodes(n, func(c *html.Node) walkAction {
+		if c.Type != html.ElementNode {
+			return walkContinue
+		}
+		if isBoilerplate(c) || c == byline {
+			return walkSkipChildren
+		}
+		kind := c.Data
+		switch c.Data {
+		case "p", "li", "blockquote", "pre", "h2", "h3", "h4", "h5", "h6", "dt", "dd", "figcaption":
+		case "h1":
+			kind = "h2" // the title is the only h1
+		case "div", "section", "article", "main", "td":
+			if hasBlockChildren(c) {
+				return walkContinue
+			}
+			kind = "p"
+		default:
+			return walkContinue
+		}
+		text := textContent(c)
+		if kind == "pre" {
+			text = strings.Trim(preText(c), "\n")
+		}
+		switch {
+		case text == "":
+		case byline != nil && text == bylineText:
+		case headingLevel(c) > 0 && text == title:
+		case headingLevel(c) == 0 && linkDensity(c) > 0.5:
+		default:
+			switch kind {
+			case "dt", "dd", "figcaption":
+				kind = "p"
+			}
+			blocks = append(blocks, articleBlock{kind, text})
+		}
+		return walkSkipChildren
+	}, nil)
+	return blocks
+}
+
+// preText returns the text under n with its white space kept.
+func preText(n *html.Node) string {
+	var b strings.Builder
+	forEachNode(n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}, nil)
+	return b.String()
+}
+
+// articleTitle returns the page's only h1 if it has exactly one, or else
+// its title without a trailing site name such as " | Example News".
+func articleTitle(doc *html.Node) string {
+	if h1s := ElementsByTagName(doc, "h1"); len(h1s) == 1 {
+		if text := textContent(h1s[0]); text != "" {
+			return text
+		}
+	}
+	titles := ElementsByTagName(doc, "title")
+	if len(titles) == 0 {
+		return ""
+	}
+	title := textContent(titles[0])
+	for _, sep := range []string{" | ", " - ", " – ", " — ", " :: ", " » "} {
+		if i := strings.LastIndex(title, sep); i > 0 && len(strings.Fields(title[:i])) >= 3 {
+			return title[:i]
+		}
+	}
+	return title
+}
+
+// articleByline returns the author named by a meta tag or by an element
+// whose rel, itemprop, class or id says it holds the byline, and that
+// element, if any, so that it is not repeated in the article's text.
+func articleByline(doc *html.Node) (string, *html.Node) {
+	var byline string
+	for _, m := range ElementsByTagName(doc, "meta") {
+		if name, _ := attr(m, "name"); strings.EqualFold(name, "author") {
+			if content, _ := attr(m, "content"); strings.TrimSpace(content) != "" {
+				byline = strings.TrimSpace(content)
+				break
+			}
+		}
+	}
+	found := findElements(doc, 1, func(n *html.Node) bool {
+		rel, _ := attr(n, "rel")
+		itemprop, _ := attr(n, "itemprop")
+		if rel != "author" && !strings.Contains(itemprop, "author") && !bylineHints.MatchString(hints(n)) {
+			return false
+		}
+		text := textContent(n)
+		return text != "" && len(text) < 100
+	})
+	if len(found) == 0 {
+		return byline, nil
+	}
+	if byline == "" {
+		byline = textContent(found[0])
+	}
+	return byline, found[0]
+}
+
+// writeText writes the article as plain text, a blank line between blocks.
+func (a article) writeText(w io.Writer) {
+	fmt.Fprintln(w, a.Title)
+	if a.Byline != "" {
+		fmt.Fprintln(w, a.Byline)
+	}
+	for _, b := range a.Blocks {
+		fmt.Fprintln(w)
+		if b.Kind == "li" {
+			fmt.Fprint(w, "- ")
+		}
+		fmt.Fprintln(w, b.Text)
+	}
+}
+
+// writeMarkdown writes the article as Markdown under a level one heading.
+func (a article) writeMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "# %s\n", a.Title)
+	if a.Byline != "" {
+		fmt.Fprintf(w, "\n*%s*\n", a.Byline)
+	}
+	previous := ""
+	for _, b := range a.Blocks {
+		if !(b.Kind == "li" && previous == "li") {
+			fmt.Fprintln(w) // list items stay together
+		}
+		previous = b.Kind
+		switch {
+		case b.Kind == "li":
+			fmt.Fprintf(w, "- %s\n", b.Text)
+		case b.Kind == "blockquote":
+			fmt.Fprintf(w, "> %s\n", b.Text)
+		case b.Kind == "pre":
+			fmt.Fprintf(w, "```\n%s\n```\n", b.Text)
+		case len(b.Kind) == 2 && b.Kind[0] == 'h':
+			fmt.Fprintf(w, "%s %s\n", strings.Repeat("#", int(b.Kind[1]-'0')), b.Text)
+		default:
+			fmt.Fprintln(w, b.Text)
+		}
+	}
+}
+
+// articleCommand implements
+// "article [-crawl] [-limit n] [-timeout d] [-format text|markdown|json] [URL|FILE|-]...".
+// It prints the main content of each page, separated by a line of dashes,
+// or all of them as JSON.
+func articleCommand(args []string) error {
+	fs := flag.NewFlagSet("article", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for fetching each URL")
+	crawl := fs.Bool("crawl", false, "crawl from each URL, staying on its host")
+	limit := fs.Int("limit", 100, "pages to fetch per crawl; 0 for no limit")
+	format := fs.String("format", "text", "output format: text, markdown or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch *format {
+	case "text", "markdown", "json":
+	default:
+		return fmt.Errorf("unknown article format %q", *format)
+	}
+	srcs := fs.Args()
+	if len(srcs) == 0 {
+		srcs = []string{"-"}
+	}
+	client := &http.Client{Timeout: *timeout}
+	articles := []article{}
+	err := eachPage(client, srcs, *crawl, *limit, func(src string, p *page) {
+		a := extractArticle(p.Doc)
+		a.URL = src
+		articles = append(articles, a)
+	})
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		return writeIndentedJSON(os.Stdout, articles)
+	}
+	for i, a := range articles {
+		if i > 0 {
+			fmt.Print("\n---\n\n")
+		}
+		if *format == "markdown" {
+			a.writeMarkdown(os.Stdout)
+		} else {
+			a.writeText(os.Stdout)
+		}
+	}
+	return nil
+}
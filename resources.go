@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// resourceKind says what a reference from a page is for.
+type resourceKind string
+
+const (
+	kindAnchor     resourceKind = "anchor"     // a and area href
+	kindImage      resourceKind = "image"      // img src and srcset
+	kindScript     resourceKind = "script"     // script src
+	kindStylesheet resourceKind = "stylesheet" // link rel=stylesheet
+	kindIcon       resourceKind = "icon"       // link rel=icon and apple-touch-icon
+	kindPreload    resourceKind = "preload"    // link rel=preload, prefetch and friends
+	kindLink       resourceKind = "link"       // any other link href, such as canonical or alternate
+	kindFrame      resourceKind = "frame"      // iframe and frame src
+	kindSource     resourceKind = "source"     // source src and srcset, in picture, video and audio
+	kindMedia      resourceKind = "media"      // video, audio and track src
+	kindPoster     resourceKind = "poster"     // video poster
+	kindEmbed      resourceKind = "embed"      // embed src and object data
+	kindForm       resourceKind = "form"       // form action
+	kindRefresh    resourceKind = "refresh"    // meta http-equiv=refresh
+	kindCSS        resourceKind = "css"        // url() in style elements and attributes
+)
+
+// resourceRef is one reference from a page to another URL.
+type resourceRef struct {
+	Kind resourceKind `json:"kind"`
+	URL  string       `json:"url"`
+}
+
+// pageResources returns every reference in doc, resolved against the
+// document base for docURL, in document order and without repeats. data:
+// and javascript: URLs, which name nothing to fetch, are left out.
+func pageResources(doc *html.Node, docURL *url.URL) []resourceRef {
+	base := documentBase(doc, docURL)
+	var refs []resourceRef
+	seen := make(map[resourceRef]bool)
+	add := func(kind resourceKind, ref string) {
+		ref = strings.TrimSpace(ref)
+		lower := strings.ToLower(ref)
+		if ref == "" || strings.HasPrefix(lower, "data:") || strings.HasPrefix(lower, "javascript:") {
+			return
+		}
+		u, err := resolve(base, ref)
+		if err != nil {
+			return // ignore bad URLs
+		}
+		r := resourceRef{kind, u.String()}
+		if !seen[r] {
+			seen[r] = true
+			refs = append(refs, r)
+		}
+	}
+	addAttr := func(n *html.Node, kind resourceKind, key string) {
+		if v, ok := attr(n, key); ok {
+			add(kind, v)
+		}
+	}
+	addSrcset := func(n *html.Node, kind resourceKind) {
+		if v, ok := attr(n, "srcset"); ok {
+			for _, ref := range srcsetURLs(v) {
+				add(kind, ref)
+			}
+		}
+	}
+
+	forEachNode(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		if style, ok := attr(n, "style"); ok {
+			for _, ref := range cssURLs(style) {
+				add(kindCSS, ref)
+			}
+		}
+		switch n.Data {
+		case "a", "area":
+			addAttr(n, kindAnchor, "href")
+		case "img":
+			addAttr(n, kindImage, "src")
+			addSrcset(n, kindImage)
+		case "script":
+			addAttr(n, kindScript, "src")
+		case "link":
+			addAttr(n, linkKind(n), "href")
+		case "iframe", "frame":
+			addAttr(n, kindFrame, "src")
+		case "source":
+			addAttr(n, kindSource, "src")
+			addSrcset(n, kindSource)
+		case "video", "audio", "track":
+			addAttr(n, kindMedia, "src")
+			addAttr(n, kindPoster, "poster")
+		case "embed":
+			addAttr(n, kindEmbed, "src")
+		case "object":
+			addAttr(n, kindEmbed, "data")
+		case "form":
+			addAttr(n, kindForm, "action")
+		case "meta":
+			if equiv, _ := attr(n, "http-equiv"); strings.EqualFold(equiv, "refresh") {
+				content, _ := attr(n, "content")
+				if ref, ok := refreshURL(content); ok {
+					add(kindRefresh, ref)
+				}
+			}
+		case "style":
+			if n.FirstChild != nil {
+				for _, ref := range cssURLs(n.FirstChild.Data) {
+					add(kindCSS, ref)
+				}
+			}
+		}
+	}, nil)
+	return refs
+}
+
+// linkKind classifies a link element by its rel tokens.
+func linkKind(n *html.Node) resourceKind {
+	rel, _ := attr(n, "rel")
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		switch r {
+		case "stylesheet":
+			return kindStylesheet
+		case "icon", "apple-touch-icon", "mask-icon":
+			return kindIcon
+		case "preload", "prefetch", "modulepreload", "prerender", "preconnect", "dns-prefetch":
+			return kindPreload
+		}
+	}
+	return kindLink
+}
+
+// srcsetURLs returns the URLs of the image candidates in a srcset, as in
+// "a.png 1x, b.png 2x". A URL may itself contain commas, but not end in one.
+func srcsetURLs(srcset string) []string {
+	var refs []string
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return refs
+		}
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end < 0 {
+			end = len(s)
+		}
+		ref := s[:end]
+		s = s[end:]
+		if trimmed := strings.TrimRight(ref, ","); trimmed != ref {
+			refs = append(refs, trimmed) // a candidate without descriptors
+			continue
+		}
+		refs = append(refs, ref)
+		// Skip the descriptors up to the next comma outside parentheses.
+		depth := 0
+		i := 0
+		for ; i < len(s) && !(s[i] == ',' && depth == 0); i++ {
+			switch s[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		s = s[i:]
+	}
+}
+
+// cssURLs returns the arguments of the url() functions and the strings
+// of the @import rules in css.
+func cssURLs(css string) []string {
+	var refs []string
+	lower := strings.ToLower(css)
+	for i := 0; i < len(css); {
+		j := strings.Index(lower[i:], "url(")
+		k := strings.Index(lower[i:], "@import")
+		switch {
+		case j < 0 && k < 0:
+			return refs
+		case k >= 0 && (j < 0 || k < j):
+			// @import "a.css" takes a string; @import url(a.css) is
+			// found as a url() next time round.
+			i += k + len("@import")
+			rest := strings.TrimLeft(css[i:], " \t\n\r\f")
+			if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+				if end := strings.IndexByte(rest[1:], rest[0]); end >= 0 {
+					refs = append(refs, rest[1:1+end])
+				}
+			}
+		default:
+			i += j + len("url(")
+			rest := strings.TrimLeft(css[i:], " \t\n\r\f")
+			var ref string
+			if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+				end := strings.IndexByte(rest[1:], rest[0])
+				if end < 0 {
+					return refs
+				}
+				ref = rest[1 : 1+end]
+			} else {
+				end := strings.IndexByte(rest, ')')
+				if end < 0 {
+					return refs
+				}
+				ref = strings.TrimSpace(rest[:end])
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// refreshURL returns the URL in the content of a meta refresh, as in
+// "5; url=next.html", and whether there is one.
+func refreshURL(content string) (string, bool) {
+	i := strings.IndexAny(content, ";,")
+	if i < 0 {
+		return "", false
+	}
+	rest := strings.TrimLeft(content[i+1:], " \t\n\r\f")
+	if len(rest) >= 3 && strings.EqualFold(rest[:3], "url") {
+		after := strings.TrimLeft(rest[3:], " \t\n\r\f")
+		if strings.HasPrefix(after, "=") {
+			rest = strings.TrimLeft(after[1:], " \t\n\r\f")
+		}
+	}
+	if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+		if end := strings.IndexByte(rest[1:], rest[0]); end >= 0 {
+			rest = rest[1 : 1+end]
+		} else {
+			rest = rest[1:]
+		}
+	}
+	return rest, rest != ""
+}
+
+// filterResources returns the references of the given kinds; no kinds
+// means all of them.
+func filterResources(refs []resourceRef, kinds ...resourceKind) []resourceRef {
+	if len(kinds) == 0 {
+		return refs
+	}
+	var kept []resourceRef
+	for _, r := range refs {
+		for _, k := range kinds {
+			if r.Kind == k {
+				kept = append(kept, r)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// ExtractResources is Extract for every kind of reference, not only links.
+func ExtractResources(url string) ([]resourceRef, error) {
+	p, err := loadURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return pageResources(p.Doc, p.URL), nil
+}
+
+// linksCommand implements "links [-kind k,...] [-timeout d] [URL|FILE|-]...".
+// It prints the kind and URL of each reference, one per line.
+func linksCommand(args []string) error {
+	fs := flag.NewFlagSet("links", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for fetching URLs")
+	kinds := fs.String("kind", "", "comma separated kinds to print, such as image,script; all if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var want []resourceKind
+	for k := range tagSet(*kinds) {
+		want = append(want, resourceKind(k))
+	}
+	srcs := fs.Args()
+	if len(srcs) == 0 {
+		srcs = []string{"-"}
+	}
+	client := &http.Client{Timeout: *timeout}
+	for _, src := range srcs {
+		p, err := loadPage(context.Background(), client, src)
+		if err != nil {
+			return err
+		}
+		for _, r := range filterResources(pageResources(p.Doc, p.URL), want...) {
+			fmt.Printf("%s\t%s\n", r.Kind, r.URL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ruleKind int
+
+const (
+	ruleCourse ruleKind = iota // a single course must be completed
+	ruleAll                    // every part must hold
+	ruleAny                    // at least one part must hold
+)
+
+// prereqRule is a prerequisite expression such as
+// "data structures AND (discrete math OR formal languages)".
+type prereqRule struct {
+	Kind   ruleKind
+	Course string
+	Parts  []*prereqRule
+}
+
+func course(name string) *prereqRule {
+	return &prereqRule{Kind: ruleCourse, Course: name}
+}
+
+func allOf(parts ...*prereqRule) *prereqRule {
+	return &prereqRule{Kind: ruleAll, Parts: parts}
+}
+
+func anyOf(parts ...*prereqRule) *prereqRule {
+	return &prereqRule{Kind: ruleAny, Parts: parts}
+}
+
+// satisfied reports whether the courses in done fulfil the rule.
+// A nil rule has no requirements.
+func (r *prereqRule) satisfied(done map[string]bool) bool {
+	if r == nil {
+		return true
+	}
+	switch r.Kind {
+	case ruleCourse:
+		return done[r.Course]
+	case ruleAll:
+		for _, p := range r.Parts {
+			if !p.satisfied(done) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, p := range r.Parts {
+		if p.satisfied(done) {
+			return true
+		}
+	}
+	return false
+}
+
+// courses appends every course mentioned by the rule to list.
+func (r *prereqRule) courses(list []string) []string {
+	if r == nil {
+		return list
+	}
+	if r.Kind == ruleCourse {
+		return append(list, r.Course)
+	}
+	for _, p := range r.Parts {
+		list = p.courses(list)
+	}
+	return list
+}
+
+func (r *prereqRule) String() string {
+	if r == nil {
+		return ""
+	}
+	if r.Kind == ruleCourse {
+		return r.Course
+	}
+	op := " AND "
+	if r.Kind == ruleAny {
+		op = " OR "
+	}
+	var parts []string
+	for _, p := range r.Parts {
+		s := p.String()
+		if p.Kind != ruleCourse && p.Kind != r.Kind {
+			s = "(" + s + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, op)
+}
+
+// parseRule parses an expression of course names joined by AND and OR,
+// with parentheses for grouping. AND binds tighter than OR.
+func parseRule(s string) (*prereqRule, error) {
+	p := ruleParser{tokens: tokenizeRule(s)}
+	r, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("parsing rule %q: unexpected %q", s, p.tokens[p.pos])
+	}
+	return r, nil
+}
+
+// mustParseRule is parseRule for rules known to be well formed. It panics
+// if s does not parse.
+func mustParseRule(s string) *prereqRule {
+	r, err := parseRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// tokenizeRule splits s into "(", ")", "AND", "OR" and course names,
+// where a course name is a run of other words.
+func tokenizeRule(s string) []string {
+	s = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s)
+	var tokens, name []string
+	flush := func() {
+		if len(name) > 0 {
+			tokens = append(tokens, strings.Join(name, " "))
+			name = nil
+		}
+	}
+	for _, w := range strings.Fields(s) {
+		switch w {
+		case "(", ")", "AND", "OR":
+			flush()
+			tokens = append(tokens, w)
+		default:
+			name = append(name, w)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type ruleParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *ruleParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *ruleParser) parseOr() (*prereqRule, error) {
+	return p.parseList("OR", ruleAny, p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (*prereqRule, error) {
+	return p.parseList("AND", ruleAll, p.parseFactor)
+}
+
+func (p *ruleParser) parseList(op string, kind ruleKind, next func() (*prereqRule, error)) (*prereqRule, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	parts := []*prereqRule{first}
+	for p.peek() == op {
+		p.pos++
+		r, err := next()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, r)
+	}
+	if len(parts) == 1 {
+		return first, nil
+	}
+	return &prereqRule{Kind: kind, Parts: parts}, nil
+}
+
+func (p *ruleParser) parseFactor() (*prereqRule, error) {
+	switch tok := p.peek(); tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of rule")
+	case "(":
+		p.pos++
+		r, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ) in rule")
+		}
+		p.pos++
+		return r, nil
+	case ")", "AND", "OR":
+		return nil, fmt.Errorf("unexpected %q in rule", tok)
+	default:
+		p.pos++
+		return course(tok), nil
+	}
+}
+
+// ruleCatalog describes courses with rule prerequisites and corequisite
+// groups, whose courses have to be taken in the same term.
+type ruleCatalog struct {
+	Rules  map[string]*prereqRule
+	Coreqs [][]string
+}
+
+// catalogOf turns a prereqs style map into a catalog where every course
+// requires all of its prerequisites.
+func catalogOf(m map[string]map[string]bool) ruleCatalog {
+	c := ruleCatalog{Rules: make(map[string]*prereqRule)}
+	for name, deps := range m {
+		var parts []*prereqRule
+		for d := range deps {
+			parts = append(parts, course(d))
+		}
+		sort.Slice(parts, func(i, j int) bool { return parts[i].Course < parts[j].Course })
+		c.Rules[name] = allOf(parts...)
+	}
+	return c
+}
+
+// units groups the courses of the catalog: every course is alone except
+// those joined through corequisite groups. Units and their courses are
+// sorted.
+func (c ruleCatalog) units() [][]string {
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(x string) string {
+		if parent[x] == "" || parent[x] == x {
+			parent[x] = x
+			return x
+		}
+		parent[x] = find(parent[x])
+		return parent[x]
+	}
+	for name, r := range c.Rules {
+		find(name)
+		for _, d := range r.courses(nil) {
+			find(d)
+		}
+	}
+	for _, g := range c.Coreqs {
+		for _, name := range g {
+			find(name)
+			if a, b := find(g[0]), find(name); a != b {
+				parent[b] = a
+			}
+		}
+	}
+	groups := make(map[string][]string)
+	for name := range parent {
+		root := find(name)
+		groups[root] = append(groups[root], name)
+	}
+	var units [][]string
+	for _, g := range groups {
+		sort.Strings(g)
+		units = append(units, g)
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i][0] < units[j][0] })
+	return units
+}
+
+// planTerms schedules the catalog term by term, taking every unit whose
+// rules are met by the courses of earlier terms. A course in a corequisite
+// group may also rely on the other courses of its group. If some courses
+// can never be taken, the terms planned so far are returned along with an
+// error naming them.
+func (c ruleCatalog) planTerms() ([][]string, error) {
+	remaining := c.units()
+	done := make(map[string]bool)
+	var terms [][]string
+	for len(remaining) > 0 {
+		var term []string
+		var blocked [][]string
+		for _, unit := range remaining {
+			with := make(map[string]bool)
+			for k := range done {
+				with[k] = true
+			}
+			for _, name := range unit {
+				with[name] = true
+			}
+			ok := true
+			for _, name := range unit {
+				if !c.Rules[name].satisfied(with) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				term = append(term, unit...)
+			} else {
+				blocked = append(blocked, unit)
+			}
+		}
+		if len(term) == 0 {
+			var names []string
+			for _, unit := range blocked {
+				names = append(names, unit...)
+			}
+			return terms, fmt.Errorf("infeasible: no order satisfies %s", strings.Join(names, ", "))
+		}
+		sort.Strings(term)
+		for _, name := range term {
+			done[name] = true
+		}
+		terms = append(terms, term)
+		remaining = blocked
+	}
+	return terms, nil
+}
+
+// isFeasible reports whether every course of the catalog can eventually
+// be taken.
+func (c ruleCatalog) isFeasible() bool {
+	_, err := c.planTerms()
+	return err == nil
+}
+
+// ruleCatalogExample expresses course rules a plain prereqs map cannot:
+// a choice between two prerequisites and a lab taken with its lecture.
+var ruleCatalogExample = ruleCatalog{
+	Rules: map[string]*prereqRule{
+		"algorithms":       mustParseRule("data structures AND (discrete math OR formal languages)"),
+		"data structures":  course("intro to programming"),
+		"discrete math":    course("intro to programming"),
+		"formal languages": course("intro to programming"),
+		"physics":          course("calculus"),
+		"physics lab":      course("physics"),
+	},
+	Coreqs: [][]string{{"physics", "physics lab"}},
+}
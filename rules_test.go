@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"calculus", "calculus"},
+		{"  intro   to programming ", "intro to programming"},
+		{"a AND b AND c", "a AND b AND c"},
+		{"a OR b AND c", "a OR (b AND c)"},
+		{"(a OR b) AND c", "(a OR b) AND c"},
+		{"((a))", "a"},
+		{"data structures AND (discrete math OR formal languages)", "data structures AND (discrete math OR formal languages)"},
+	} {
+		r, err := parseRule(tt.in)
+		if err != nil {
+			t.Errorf("parseRule(%q): %v", tt.in, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("parseRule(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		// The printed rule parses back into the same rule.
+		if again, err := parseRule(r.String()); err != nil || again.String() != r.String() {
+			t.Errorf("parseRule(%q) = %v, %v", r.String(), again, err)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, in := range []string{"", "AND", "a AND", "a OR OR b", "(a", "a)", "a AND (b OR", "()"} {
+		if r, err := parseRule(in); err == nil {
+			t.Errorf("parseRule(%q) = %v, want an error", in, r)
+		}
+	}
+}
+
+func TestRuleSatisfied(t *testing.T) {
+	r := mustParseRule("data structures AND (discrete math OR formal languages)")
+	for _, tt := range []struct {
+		done string
+		want bool
+	}{
+		{"", false},
+		{"data structures", false},
+		{"data structures,discrete math", true},
+		{"data structures,formal languages", true},
+		{"discrete math,formal languages", false},
+	} {
+		done := make(map[string]bool)
+		for _, c := range strings.Split(tt.done, ",") {
+			done[c] = true
+		}
+		if got := r.satisfied(done); got != tt.want {
+			t.Errorf("satisfied with %q = %v, want %v", tt.done, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogFeasible(t *testing.T) {
+	if !ruleCatalogExample.isFeasible() {
+		t.Errorf("ruleCatalogExample is not feasible")
+	}
+	if !catalogOf(prereqs).isFeasible() {
+		t.Errorf("catalogOf(prereqs) is not feasible")
+	}
+	cyclic := catalogOf(map[string]map[string]bool{
+		"a": {"b": true},
+		"b": {"c": true},
+		"c": {"a": true},
+		"d": {},
+	})
+	if cyclic.isFeasible() {
+		t.Errorf("a catalog with a cycle is feasible")
+	}
+	// A catalog of AND rules plans the same terms as its prerequisites'
+	// layers: each course comes right after its last prerequisite.
+	terms, err := catalogOf(prereqs).planTerms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	term := make(map[string]int)
+	for i, names := range terms {
+		for _, name := range names {
+			term[name] = i
+		}
+	}
+	for name, deps := range prereqs {
+		want := 0
+		for d := range deps {
+			if term[d]+1 > want {
+				want = term[d] + 1
+			}
+		}
+		if term[name] != want {
+			t.Errorf("%s in term %d, want %d", name, term[name], want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// commands are the subcommands main runs instead of the exercises,
+// each given the arguments after its name.
+var commands = map[string]func(args []string) error{
+	"outline": outlineCommand,
+	"stats":   statsCommand,
+	"audit":   auditCommand,
+	"links":   linksCommand,
+	"article": articleCommand,
+	"meta":    metaCommand,
+	"diff":    diffCommand,
+}
+
+// fetch makes a GET request for url with client, honoring ctx.
+// Any status other than 200 is reported as an error and the body closed.
+func fetch(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("getting %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// isURL reports whether src should be fetched rather than opened as a file.
+func isURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+// htmlSource is an opened HTML source.
+type htmlSource struct {
+	io.ReadCloser
+	// URL is what relative references in the source resolve against: the
+	// final URL after redirects, a file URL for local files and nil for
+	// standard input.
+	URL         *url.URL
+	ContentType string // from the response header; empty unless fetched
+}
+
+// openHTML opens an HTML source: "-" is standard input, http and https
+// URLs are fetched with client, and anything else is a local file.
+func openHTML(ctx context.Context, client *http.Client, src string) (*htmlSource, error) {
+	switch {
+	case src == "-":
+		return &htmlSource{ReadCloser: io.NopCloser(os.Stdin)}, nil
+	case isURL(src):
+		resp, err := fetch(ctx, client, src)
+		if err != nil {
+			return nil, err
+		}
+		return &htmlSource{resp.Body, resp.Request.URL, resp.Header.Get("Content-Type")}, nil
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	return &htmlSource{ReadCloser: f, URL: &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}}, nil
+}
+
+// page is a parsed HTML document, the URL it was read from, as returned by
+// openHTML, and the encoding it was decoded from.
+type page struct {
+	Doc      *html.Node
+	URL      *url.URL
+	Encoding pageEncoding
+}
+
+// loadPage reads src with openHTML, decodes it to UTF-8 and parses it.
+func loadPage(ctx context.Context, client *http.Client, src string) (*page, error) {
+	s, err := openHTML(ctx, client, src)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	r, enc, err := decodeHTML(s, s.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", src, err)
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s as HTML: %v", src, err)
+	}
+	return &page{Doc: doc, URL: s.URL, Encoding: enc}, nil
+}
+
+// parseHTML is loadPage for callers that need only the document.
+func parseHTML(ctx context.Context, client *http.Client, src string) (*html.Node, error) {
+	p, err := loadPage(ctx, client, src)
+	if err != nil {
+		return nil, err
+	}
+	return p.Doc, nil
+}
+
+// writeIndentedJSON writes v to w as JSON indented by two spaces, ending
+// with a newline.
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// outline writes the element outline of the HTML document read from r.
+func outline(w io.Writer, r io.Reader) error {
+	r, _, err := decodeHTML(r, "")
+	if err != nil {
+		return err
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return err
+	}
+	writeOutline(w, doc)
+	return nil
+}
+
+// outlineSource writes the outline of src, opened with openHTML.
+func outlineSource(ctx context.Context, client *http.Client, w io.Writer, src string) error {
+	doc, err := parseHTML(ctx, client, src)
+	if err != nil {
+		return err
+	}
+	writeOutline(w, doc)
+	return nil
+}
+
+// outlineCommand implements "outline [flags] [URL|FILE|-]...".
+// Without arguments it reads standard input. With -xpath it prints the
+// result of the expression for each document instead of the outline,
+// with -toc the table of contents built from the headings, and with
+// -pretty the whole document.
+func outlineCommand(args []string) error {
+	fs := flag.NewFlagSet("outline", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for fetching URLs")
+	pretty := fs.Bool("pretty", false, "print the whole document, not just element names")
+	query := fs.String("xpath", "", "print the nodes or value selected by this XPath 1.0 expression")
+	format := fs.String("format", "text", "outline format: text, json, yaml, compact or markdown")
+	depth := fs.Int("depth", 0, "outline at most this many levels of elements; 0 for all")
+	include := fs.String("include", "", "comma separated tags to show; the others are skipped over")
+	exclude := fs.String("exclude", "", "comma separated tags to leave out with their contents")
+	toc := fs.Bool("toc", false, "print the table of contents from the headings, as Markdown or, with -format json, JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch *format {
+	case "text", "json", "yaml", "compact", "markdown":
+	default:
+		return fmt.Errorf("unknown outline format %q", *format)
+	}
+	opts := outlineOptions{MaxDepth: *depth, Include: tagSet(*include), Exclude: tagSet(*exclude)}
+	var xpath *XPath
+	if *query != "" {
+		var err error
+		if xpath, err = CompileXPath(*query); err != nil {
+			return err
+		}
+	}
+	srcs := fs.Args()
+	if len(srcs) == 0 {
+		srcs = []string{"-"}
+	}
+	client := &http.Client{Timeout: *timeout}
+	for _, src := range srcs {
+		doc, err := parseHTML(context.Background(), client, src)
+		if err != nil {
+			return err
+		}
+		switch {
+		case xpath != nil:
+			v, err := xpath.Evaluate(doc)
+			if err != nil {
+				return err
+			}
+			writeXPathResult(os.Stdout, v)
+		case *toc:
+			contents := headingOutline(doc)
+			if *format == "json" {
+				if err := contents.writeJSON(os.Stdout); err != nil {
+					return err
+				}
+				break
+			}
+			contents.writeMarkdown(os.Stdout)
+			for _, warning := range contents.Warnings {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", src, warning)
+			}
+		case *pretty:
+			prettyPrint(os.Stdout, doc)
+		default:
+			if err := writeOutlineFormat(os.Stdout, doc, *format, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeOutlineFormat writes the outline of doc selected by opts in one of
+// the formats accepted by outlineCommand.
+func writeOutlineFormat(w io.Writer, doc *html.Node, format string, opts outlineOptions) error {
+	nodes := buildOutline(doc, opts)
+	switch format {
+	case "json":
+		return writeOutlineJSON(w, nodes)
+	case "yaml":
+		writeOutlineYAML(w, nodes)
+	case "compact", "markdown":
+		writeOutlineCompact(w, nodes, format == "markdown")
+	default:
+		writeOutlineText(w, nodes)
+	}
+	return nil
+}
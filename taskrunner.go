@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Task is a named unit of work that may run once all of its Deps finished.
+type Task struct {
+	Name string
+	Deps []string
+	Run  func(ctx context.Context) error
+}
+
+// TaskStatus is the final state of a task after runTasks returns.
+type TaskStatus int
+
+const (
+	TaskSkipped TaskStatus = iota
+	TaskSucceeded
+	TaskFailed
+)
+
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskSucceeded:
+		return "ok"
+	case TaskFailed:
+		return "failed"
+	}
+	return "skipped"
+}
+
+// TaskResult reports what happened to a single task.
+type TaskResult struct {
+	Name     string
+	Status   TaskStatus
+	Err      error
+	Duration time.Duration
+}
+
+type taskDone struct {
+	name string
+	err  error
+	dur  time.Duration
+}
+
+// taskGraph turns the tasks into the prerequisite map used by topoSort.
+func taskGraph(tasks []Task) (map[string]map[string]bool, map[string]Task, error) {
+	graph := make(map[string]map[string]bool)
+	byName := make(map[string]Task)
+	for _, t := range tasks {
+		if _, dup := byName[t.Name]; dup {
+			return nil, nil, fmt.Errorf("duplicate task %q", t.Name)
+		}
+		byName[t.Name] = t
+		graph[t.Name] = make(map[string]bool)
+		for _, d := range t.Deps {
+			graph[t.Name][d] = true
+		}
+	}
+	for name, deps := range graph {
+		for d := range deps {
+			if _, ok := byName[d]; !ok {
+				return nil, nil, fmt.Errorf("task %q depends on unknown task %q", name, d)
+			}
+		}
+	}
+	return graph, byName, nil
+}
+
+// runTasks runs the tasks on at most workers goroutines, starting each one
+// as soon as its dependencies succeeded. Ready tasks are dispatched in the
+// order given by topoSort. If keepGoing is false the first failure cancels
+// the context passed to the running tasks and nothing new is started;
+// otherwise only the dependents of a failed task are skipped.
+// The report is returned in topological order together with the first error.
+func runTasks(ctx context.Context, tasks []Task, workers int, keepGoing bool) ([]TaskResult, error) {
+	graph, byName, err := taskGraph(tasks)
+	if err != nil {
+		return nil, err
+	}
+	order := topoSort(graph)
+	rank := order.Rank
+	// topoSort still returns an order for a cyclic graph, but some edge
+	// has to point forward in it.
+	for name, deps := range graph {
+		for d := range deps {
+			if rank(d) >= rank(name) {
+				return nil, fmt.Errorf("dependency cycle through %q -> %q", name, d)
+			}
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pending := make(map[string]int)
+	dependents := make(map[string][]string)
+	var ready []string
+	for name, deps := range graph {
+		pending[name] = len(deps)
+		for d := range deps {
+			dependents[d] = append(dependents[d], name)
+		}
+		if len(deps) == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	jobs := make(chan string)
+	done := make(chan taskDone)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for name := range jobs {
+				start := time.Now()
+				err := byName[name].Run(ctx)
+				done <- taskDone{name, err, time.Since(start)}
+			}
+		}()
+	}
+
+	results := make(map[string]TaskResult)
+	var firstErr error
+	stopped := false
+	running := 0
+	ctxDone := ctx.Done()
+	for {
+		// Checked first, as select would pick a ready task as often as a
+		// done context.
+		if !stopped && ctx.Err() != nil {
+			stopped = true
+			if firstErr == nil {
+				firstErr = ctx.Err()
+			}
+		}
+		if stopped {
+			ready = nil
+		}
+		sort.Slice(ready, func(i, j int) bool { return rank(ready[i]) < rank(ready[j]) })
+		var send chan string
+		var next string
+		if len(ready) > 0 {
+			send = jobs
+			next = ready[0]
+		}
+		if send == nil && running == 0 {
+			break
+		}
+		select {
+		case send <- next:
+			ready = ready[1:]
+			running++
+		case d := <-done:
+			running--
+			if d.err != nil {
+				results[d.name] = TaskResult{d.name, TaskFailed, d.err, d.dur}
+				if firstErr == nil {
+					firstErr = fmt.Errorf("task %s: %v", d.name, d.err)
+				}
+				if !keepGoing {
+					stopped = true
+					cancel()
+				}
+				continue
+			}
+			results[d.name] = TaskResult{d.name, TaskSucceeded, nil, d.dur}
+			for _, dep := range dependents[d.name] {
+				pending[dep]--
+				if pending[dep] == 0 {
+					ready = append(ready, dep)
+				}
+			}
+		case <-ctxDone:
+			ctxDone = nil // stopped at the top of the loop
+		}
+	}
+	close(jobs)
+
+	report := make([]TaskResult, 0, order.Len())
+	for _, name := range order.Courses {
+		if r, ok := results[name]; ok {
+			report = append(report, r)
+			continue
+		}
+		r := TaskResult{Name: name, Status: TaskSkipped}
+		for _, d := range byName[name].Deps {
+			if results[d].Status != TaskSucceeded {
+				r.Err = fmt.Errorf("dependency %s did not succeed", d)
+				break
+			}
+		}
+		report = append(report, r)
+	}
+	return report, firstErr
+}
+
+// printTaskReport writes one line per task: status, duration and error.
+func printTaskReport(w io.Writer, report []TaskResult) {
+	for _, r := range report {
+		if r.Err != nil {
+			fmt.Fprintf(w, "%-8s %-24s %10v  %v\n", r.Status, r.Name, r.Duration, r.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%-8s %-24s %10v\n", r.Status, r.Name, r.Duration)
+	}
+}
+
+// courseTasks builds one task per course of m that only prints its name.
+func courseTasks(m map[string]map[string]bool, w io.Writer) []Task {
+	seen := make(map[string]bool)
+	var tasks []Task
+	add := func(name string, deps map[string]bool) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		t := Task{Name: name}
+		for d := range deps {
+			t.Deps = append(t.Deps, d)
+		}
+		t.Run = func(ctx context.Context) error {
+			fmt.Fprintf(w, "taking %s\n", name)
+			return nil
+		}
+		tasks = append(tasks, t)
+	}
+	for course, deps := range m {
+		add(course, deps)
+		for d := range deps {
+			add(d, m[d])
+		}
+	}
+	return tasks
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// taskLog records which tasks ran, from any goroutine.
+type taskLog struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (l *taskLog) task(name string, run func(ctx context.Context) error, deps ...string) Task {
+	return Task{Name: name, Deps: deps, Run: func(ctx context.Context) error {
+		l.mu.Lock()
+		l.ran = append(l.ran, name)
+		l.mu.Unlock()
+		if run == nil {
+			return nil
+		}
+		return run(ctx)
+	}}
+}
+
+func (l *taskLog) names() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := append([]string(nil), l.ran...)
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+// statuses returns the status of each task in the report by name.
+func statuses(report []TaskResult) map[string]TaskStatus {
+	s := make(map[string]TaskStatus)
+	for _, r := range report {
+		s[r.Name] = r.Status
+	}
+	return s
+}
+
+var errTask = errors.New("task failed")
+
+func TestRunTasksStopsOnFirstFailure(t *testing.T) {
+	var log taskLog
+	started := make(chan struct{})
+	tasks := []Task{
+		log.task("fail", func(ctx context.Context) error {
+			<-started // fail only once slow is running
+			return errTask
+		}),
+		log.task("slow", func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		log.task("after fail", nil, "fail"),
+		log.task("after slow", nil, "slow"),
+	}
+	report, err := runTasks(context.Background(), tasks, 2, false)
+	if err == nil || !strings.Contains(err.Error(), "task fail") {
+		t.Errorf("err = %v", err)
+	}
+	got := statuses(report)
+	want := map[string]TaskStatus{"fail": TaskFailed, "slow": TaskFailed, "after fail": TaskSkipped, "after slow": TaskSkipped}
+	for name, s := range want {
+		if got[name] != s {
+			t.Errorf("%s: %v, want %v", name, got[name], s)
+		}
+	}
+	for _, r := range report {
+		if r.Name == "fail" && !errors.Is(r.Err, errTask) {
+			t.Errorf("fail: %v", r.Err)
+		}
+		if r.Name == "slow" && !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("slow was not cancelled: %v", r.Err)
+		}
+	}
+	if ran := log.names(); ran != "fail slow" {
+		t.Errorf("ran %q", ran)
+	}
+}
+
+func TestRunTasksKeepGoing(t *testing.T) {
+	var log taskLog
+	tasks := []Task{
+		log.task("a", nil),
+		log.task("b", func(ctx context.Context) error { return errTask }, "a"),
+		log.task("c", nil, "b"),
+		log.task("d", nil, "a"),
+		log.task("e", nil, "d", "c"),
+		log.task("f", nil),
+	}
+	report, err := runTasks(context.Background(), tasks, 3, true)
+	if err == nil || !strings.Contains(err.Error(), "task b") {
+		t.Errorf("err = %v", err)
+	}
+	if ran := log.names(); ran != "a b d f" {
+		t.Errorf("ran %q", ran)
+	}
+	got := statuses(report)
+	want := map[string]TaskStatus{"a": TaskSucceeded, "b": TaskFailed, "c": TaskSkipped, "d": TaskSucceeded, "e": TaskSkipped, "f": TaskSucceeded}
+	for name, s := range want {
+		if got[name] != s {
+			t.Errorf("%s: %v, want %v", name, got[name], s)
+		}
+	}
+	// The report is in topological order and says why a task was skipped.
+	rank := make(map[string]int)
+	for i, r := range report {
+		rank[r.Name] = i
+		if r.Status == TaskSkipped && r.Err == nil {
+			t.Errorf("%s was skipped without a reason", r.Name)
+		}
+	}
+	for _, task := range tasks {
+		for _, d := range task.Deps {
+			if rank[d] >= rank[task.Name] {
+				t.Errorf("%s reported before its dependency %s", task.Name, d)
+			}
+		}
+	}
+}
+
+func TestRunTasksRejectsCycle(t *testing.T) {
+	var log taskLog
+	tasks := []Task{
+		log.task("a", nil),
+		log.task("b", nil, "a", "d"),
+		log.task("c", nil, "b"),
+		log.task("d", nil, "c"),
+	}
+	report, err := runTasks(context.Background(), tasks, 2, true)
+	if err == nil || !strings.Contains(err.Error(), "cycle") || report != nil {
+		t.Errorf("runTasks = %v, %v", report, err)
+	}
+	if ran := log.names(); ran != "" {
+		t.Errorf("ran %q", ran)
+	}
+}
+
+func TestRunTasksCancelled(t *testing.T) {
+	var log taskLog
+	tasks := []Task{log.task("a", nil), log.task("b", nil, "a"), log.task("c", nil), log.task("d", nil)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// A done context has to win over ready tasks every time, not only
+	// when no worker is waiting yet.
+	for i := 0; i < 100; i++ {
+		report, err := runTasks(ctx, tasks, 4, false)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v", err)
+		}
+		for _, r := range report {
+			if r.Status != TaskSkipped {
+				t.Fatalf("%s: %v", r.Name, r.Status)
+			}
+		}
+	}
+	if ran := log.names(); ran != "" {
+		t.Errorf("ran %q", ran)
+	}
+}
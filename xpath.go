@@ -0,0 +1,518 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file parses XPath 1.0 expressions; xpatheval.go evaluates them
+// over trees built by html.Parse.
+
+type xpathTokenKind int
+
+const (
+	xtEOF     xpathTokenKind = iota
+	xtName                   // name test, function, axis or node type name; also "*" as a name test
+	xtOp                     // operator or punctuation
+	xtLiteral                // quoted string
+	xtNumber
+	xtVariable // $name
+)
+
+type xpathToken struct {
+	kind xpathTokenKind
+	val  string
+	num  float64
+	pos  int
+}
+
+// xpathError reports a syntax error in an expression.
+type xpathError struct {
+	Expr   string
+	Offset int
+	Msg    string
+}
+
+func (e *xpathError) Error() string {
+	return fmt.Sprintf("xpath %q: %s at offset %d", e.Expr, e.Msg, e.Offset)
+}
+
+var xpathOperatorNames = map[string]bool{"and": true, "or": true, "mod": true, "div": true}
+
+// tokenizeXPath splits expr into tokens, applying the XPath rule that
+// decides whether "*" and "and", "or", "mod", "div" are operators or names.
+func tokenizeXPath(expr string) ([]xpathToken, error) {
+	var tokens []xpathToken
+	// operatorContext reports whether a name or * here is an operator: there
+	// is a previous token and it is not @, ::, (, [, a comma or an operator.
+	operatorContext := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		t := tokens[len(tokens)-1]
+		if t.kind != xtOp {
+			return true
+		}
+		return t.val == ")" || t.val == "]" || t.val == "." || t.val == ".."
+	}
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, &xpathError{expr, i, "unterminated string"}
+			}
+			tokens = append(tokens, xpathToken{kind: xtLiteral, val: expr[i+1 : i+1+end], pos: start})
+			i += end + 2
+			continue
+		case '0' <= c && c <= '9' || c == '.' && i+1 < len(expr) && '0' <= expr[i+1] && expr[i+1] <= '9':
+			for i < len(expr) && ('0' <= expr[i] && expr[i] <= '9' || expr[i] == '.') {
+				i++
+			}
+			f, err := strconv.ParseFloat(expr[start:i], 64)
+			if err != nil {
+				return nil, &xpathError{expr, start, "bad number " + expr[start:i]}
+			}
+			tokens = append(tokens, xpathToken{kind: xtNumber, num: f, val: expr[start:i], pos: start})
+			continue
+		case c == '$':
+			i++
+			for i < len(expr) && isNameChar(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, xpathToken{kind: xtVariable, val: expr[start+1 : i], pos: start})
+			continue
+		case c == '*':
+			i++
+			kind := xtName
+			if operatorContext() {
+				kind = xtOp
+			}
+			tokens = append(tokens, xpathToken{kind: kind, val: "*", pos: start})
+			continue
+		case isNameChar(c) && c != '-' && c != '.':
+			for i < len(expr) && (isNameChar(expr[i]) || expr[i] == '.') {
+				i++
+			}
+			// A prefix as in svg:rect or svg:*, but not an axis as in child::.
+			if i+1 < len(expr) && expr[i] == ':' && expr[i+1] != ':' {
+				i++
+				if i < len(expr) && expr[i] == '*' {
+					i++
+				}
+				for i < len(expr) && (isNameChar(expr[i]) || expr[i] == '.') {
+					i++
+				}
+			}
+			name := expr[start:i]
+			kind := xtName
+			if operatorContext() && xpathOperatorNames[name] {
+				kind = xtOp
+			}
+			tokens = append(tokens, xpathToken{kind: kind, val: name, pos: start})
+			continue
+		}
+		op := ""
+		for _, o := range []string{"//", "::", "..", "!=", "<=", ">=", "/", "(", ")", "[", "]", ".", "@", ",", "|", "+", "-", "=", "<", ">"} {
+			if strings.HasPrefix(expr[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil, &xpathError{expr, i, fmt.Sprintf("unexpected %q", c)}
+		}
+		tokens = append(tokens, xpathToken{kind: xtOp, val: op, pos: start})
+		i += len(op)
+	}
+	return append(tokens, xpathToken{kind: xtEOF, pos: len(expr)}), nil
+}
+
+// The expression tree.
+type (
+	xpathExpr interface{}
+
+	xpathBinary struct {
+		op   string
+		l, r xpathExpr
+	}
+	xpathNegate struct{ x xpathExpr }
+	xpathCall   struct {
+		name string
+		args []xpathExpr
+	}
+	// xpathPath is a location path, optionally starting from the node-set
+	// of a filter expression instead of the context node or the root.
+	xpathPath struct {
+		filter   xpathExpr
+		absolute bool
+		steps    []xpathStep
+	}
+	xpathFilter struct {
+		primary xpathExpr
+		preds   []xpathExpr
+	}
+	xpathStep struct {
+		axis  string
+		test  xpathNodeTest
+		preds []xpathExpr
+	}
+)
+
+// xpathNodeTest is either a name test (name, "*") or, with kind set,
+// one of node(), text(), comment() and processing-instruction().
+type xpathNodeTest struct {
+	name string
+	kind string
+}
+
+var xpathAxes = map[string]bool{
+	"ancestor": true, "ancestor-or-self": true, "attribute": true, "child": true,
+	"descendant": true, "descendant-or-self": true, "following": true,
+	"following-sibling": true, "namespace": true, "parent": true,
+	"preceding": true, "preceding-sibling": true, "self": true,
+}
+
+var xpathNodeTypes = map[string]bool{
+	"comment": true, "node": true, "processing-instruction": true, "text": true,
+}
+
+// xpathFuncArgs gives the minimum and maximum number of arguments of each
+// core function; -1 means any number.
+var xpathFuncArgs = map[string][2]int{
+	"last": {0, 0}, "position": {0, 0}, "count": {1, 1}, "id": {1, 1},
+	"local-name": {0, 1}, "namespace-uri": {0, 1}, "name": {0, 1},
+	"string": {0, 1}, "concat": {2, -1}, "starts-with": {2, 2}, "contains": {2, 2},
+	"substring-before": {2, 2}, "substring-after": {2, 2}, "substring": {2, 3},
+	"string-length": {0, 1}, "normalize-space": {0, 1}, "translate": {3, 3},
+	"boolean": {1, 1}, "not": {1, 1}, "true": {0, 0}, "false": {0, 0}, "lang": {1, 1},
+	"number": {0, 1}, "sum": {1, 1}, "floor": {1, 1}, "ceiling": {1, 1}, "round": {1, 1},
+}
+
+type xpathParser struct {
+	expr   string
+	tokens []xpathToken
+	pos    int
+}
+
+func parseXPath(expr string) (xpathExpr, error) {
+	tokens, err := tokenizeXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &xpathParser{expr: expr, tokens: tokens}
+	e, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != xtEOF {
+		return nil, p.errorf("unexpected %q", t.val)
+	}
+	return e, nil
+}
+
+func (p *xpathParser) peek() xpathToken { return p.tokens[p.pos] }
+
+func (p *xpathParser) peekAt(k int) xpathToken {
+	if p.pos+k < len(p.tokens) {
+		return p.tokens[p.pos+k]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *xpathParser) next() xpathToken {
+	t := p.tokens[p.pos]
+	if t.kind != xtEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *xpathParser) isOp(val string) bool {
+	t := p.peek()
+	return t.kind == xtOp && t.val == val
+}
+
+func (p *xpathParser) expect(val string) error {
+	if !p.isOp(val) {
+		if p.peek().kind == xtEOF {
+			return p.errorf("expected %q, found end of expression", val)
+		}
+		return p.errorf("expected %q, found %q", val, p.peek().val)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *xpathParser) errorf(format string, args ...interface{}) error {
+	return &xpathError{p.expr, p.peek().pos, fmt.Sprintf(format, args...)}
+}
+
+// xpathLevels lists the binary operators from the loosest binding to the
+// tightest; all of them are left associative.
+var xpathLevels = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+func (p *xpathParser) parseBinary(level int) (xpathExpr, error) {
+	if level == len(xpathLevels) {
+		return p.parseUnary()
+	}
+	l, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		matched := false
+		for _, op := range xpathLevels[level] {
+			if t.kind == xtOp && t.val == op {
+				matched = true
+			}
+		}
+		if !matched {
+			return l, nil
+		}
+		p.next()
+		r, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = xpathBinary{t.val, l, r}
+	}
+}
+
+func (p *xpathParser) parseUnary() (xpathExpr, error) {
+	if p.isOp("-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return xpathNegate{x}, nil
+	}
+	l, err := p.parsePathExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("|") {
+		p.next()
+		r, err := p.parsePathExpr()
+		if err != nil {
+			return nil, err
+		}
+		l = xpathBinary{"|", l, r}
+	}
+	return l, nil
+}
+
+// startsFilter reports whether the next tokens begin a filter expression
+// rather than a location path.
+func (p *xpathParser) startsFilter() bool {
+	t := p.peek()
+	switch t.kind {
+	case xtLiteral, xtNumber, xtVariable:
+		return true
+	case xtOp:
+		return t.val == "("
+	case xtName:
+		return p.peekAt(1).kind == xtOp && p.peekAt(1).val == "(" && !xpathNodeTypes[t.val]
+	}
+	return false
+}
+
+func (p *xpathParser) parsePathExpr() (xpathExpr, error) {
+	if !p.startsFilter() {
+		return p.parseLocationPath()
+	}
+	primary, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	f := xpathFilter{primary: primary}
+	for p.isOp("[") {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return nil, err
+		}
+		f.preds = append(f.preds, pred)
+	}
+	var x xpathExpr = f
+	if len(f.preds) == 0 {
+		x = primary
+	}
+	if !p.isOp("/") && !p.isOp("//") {
+		return x, nil
+	}
+	path := xpathPath{filter: x}
+	if err := p.parseRelativePath(&path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func (p *xpathParser) parsePrimary() (xpathExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case xtLiteral:
+		return t.val, nil
+	case xtNumber:
+		return t.num, nil
+	case xtVariable:
+		return nil, &xpathError{p.expr, t.pos, "variables are not supported"}
+	case xtOp: // "("
+		e, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	limits, ok := xpathFuncArgs[t.val]
+	if !ok {
+		return nil, &xpathError{p.expr, t.pos, "unknown function " + t.val + "()"}
+	}
+	p.next() // (
+	call := xpathCall{name: t.val}
+	for !p.isOp(")") {
+		if len(call.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.next()
+	if len(call.args) < limits[0] || limits[1] >= 0 && len(call.args) > limits[1] {
+		return nil, &xpathError{p.expr, t.pos, fmt.Sprintf("wrong number of arguments to %s()", t.val)}
+	}
+	return call, nil
+}
+
+func (p *xpathParser) parsePredicate() (xpathExpr, error) {
+	p.next() // [
+	e, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	return e, p.expect("]")
+}
+
+func (p *xpathParser) parseLocationPath() (xpathExpr, error) {
+	var path xpathPath
+	switch {
+	case p.isOp("/"):
+		path.absolute = true
+		p.next()
+		if !p.startsStep() {
+			return path, nil
+		}
+	case p.isOp("//"):
+		path.absolute = true
+	default:
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+	}
+	if path.absolute && !p.isOp("//") {
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+	}
+	return path, p.parseRelativePath(&path)
+}
+
+// parseRelativePath appends the steps that follow "/" or "//" to path.
+func (p *xpathParser) parseRelativePath(path *xpathPath) error {
+	for p.isOp("/") || p.isOp("//") {
+		if p.next().val == "//" {
+			path.steps = append(path.steps, xpathStep{axis: "descendant-or-self", test: xpathNodeTest{kind: "node"}})
+		}
+		step, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, step)
+	}
+	return nil
+}
+
+func (p *xpathParser) startsStep() bool {
+	t := p.peek()
+	return t.kind == xtName || t.kind == xtOp && (t.val == "." || t.val == ".." || t.val == "@")
+}
+
+func (p *xpathParser) parseStep() (xpathStep, error) {
+	switch {
+	case p.isOp("."):
+		p.next()
+		return xpathStep{axis: "self", test: xpathNodeTest{kind: "node"}}, nil
+	case p.isOp(".."):
+		p.next()
+		return xpathStep{axis: "parent", test: xpathNodeTest{kind: "node"}}, nil
+	}
+	step := xpathStep{axis: "child"}
+	if p.isOp("@") {
+		p.next()
+		step.axis = "attribute"
+	} else if t := p.peek(); t.kind == xtName && p.peekAt(1).kind == xtOp && p.peekAt(1).val == "::" {
+		if !xpathAxes[t.val] {
+			return step, p.errorf("unknown axis %s", t.val)
+		}
+		if t.val == "namespace" {
+			return step, p.errorf("the namespace axis is not supported")
+		}
+		step.axis = t.val
+		p.next()
+		p.next()
+	}
+	t := p.next()
+	if t.kind != xtName {
+		if t.kind == xtEOF {
+			return step, &xpathError{p.expr, t.pos, "expected a node test, found end of expression"}
+		}
+		return step, &xpathError{p.expr, t.pos, fmt.Sprintf("expected a node test, found %q", t.val)}
+	}
+	if strings.Contains(t.val, ":") {
+		return step, &xpathError{p.expr, t.pos, "namespace prefixes are not supported"}
+	}
+	if xpathNodeTypes[t.val] && p.isOp("(") {
+		p.next()
+		if t.val == "processing-instruction" && p.peek().kind == xtLiteral {
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return step, err
+		}
+		step.test.kind = t.val
+	} else {
+		step.test.name = t.val
+	}
+	for p.isOp("[") {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return step, err
+		}
+		step.preds = append(step.preds, pred)
+	}
+	return step, nil
+}
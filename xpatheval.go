@@ -0,0 +1,802 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// XPathNode is a member of a node-set. Attributes are not nodes in an
+// html.Node tree, so an attribute node is its element plus the attribute.
+type XPathNode struct {
+	Node *html.Node
+	Attr *html.Attribute // nil unless this is an attribute node
+}
+
+// xnode is the internal form of XPathNode: attr indexes node.Attr, or is
+// -1 for the node itself.
+type xnode struct {
+	n    *html.Node
+	attr int
+}
+
+type nodeSet []xnode
+
+// XPath is a compiled XPath 1.0 expression.
+type XPath struct {
+	src  string
+	expr xpathExpr
+}
+
+// CompileXPath parses expr. Variables and namespaces are not supported.
+func CompileXPath(expr string) (*XPath, error) {
+	e, err := parseXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &XPath{src: expr, expr: e}, nil
+}
+
+func (x *XPath) String() string { return x.src }
+
+// Evaluate evaluates x with n as the context node. The result is a
+// []XPathNode in document order, a string, a float64 or a bool.
+func (x *XPath) Evaluate(n *html.Node) (interface{}, error) {
+	ev := &xpathEvaluator{}
+	v, err := ev.eval(x.expr, xpathContext{xnode{n, -1}, 1, 1})
+	if err != nil {
+		return nil, fmt.Errorf("xpath %q: %v", x.src, err)
+	}
+	if ns, ok := v.(nodeSet); ok {
+		nodes := make([]XPathNode, len(ns))
+		for i, xn := range ns {
+			nodes[i].Node = xn.n
+			if xn.attr >= 0 {
+				nodes[i].Attr = &xn.n.Attr[xn.attr]
+			}
+		}
+		return nodes, nil
+	}
+	return v, nil
+}
+
+// XPathNodes evaluates expr against doc and returns the node-set it
+// selects. It is an error for expr to yield a string, number or boolean.
+func XPathNodes(doc *html.Node, expr string) ([]XPathNode, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	v, err := x.Evaluate(doc)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]XPathNode)
+	if !ok {
+		return nil, fmt.Errorf("xpath %q: result is a %s, not a node-set", expr, xpathTypeName(v))
+	}
+	return nodes, nil
+}
+
+// writeXPathResult prints a node-set one node per line, elements in full
+// with prettyPrint, and any other result as its string value.
+func writeXPathResult(w io.Writer, v interface{}) {
+	nodes, ok := v.([]XPathNode)
+	if !ok {
+		fmt.Fprintln(w, xpathString(v))
+		return
+	}
+	for _, n := range nodes {
+		switch {
+		case n.Attr != nil:
+			fmt.Fprintf(w, "%s=\"%s\"\n", n.Attr.Key, html.EscapeString(n.Attr.Val))
+		case n.Node.Type == html.TextNode:
+			if text := strings.TrimSpace(n.Node.Data); text != "" {
+				fmt.Fprintln(w, text)
+			}
+		default:
+			prettyPrint(w, n.Node)
+		}
+	}
+}
+
+type xpathContext struct {
+	node      xnode
+	pos, size int
+}
+
+// xpathEvaluator holds the document order index, built on first use.
+type xpathEvaluator struct {
+	order map[*html.Node]int
+}
+
+func (ev *xpathEvaluator) eval(e xpathExpr, ctx xpathContext) (interface{}, error) {
+	switch e := e.(type) {
+	case string, float64:
+		return e, nil
+	case xpathNegate:
+		v, err := ev.eval(e.x, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return -xpathNumber(v), nil
+	case xpathBinary:
+		return ev.evalBinary(e, ctx)
+	case xpathCall:
+		return ev.call(e, ctx)
+	case xpathFilter:
+		v, err := ev.eval(e.primary, ctx)
+		if err != nil {
+			return nil, err
+		}
+		ns, ok := v.(nodeSet)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply a predicate to a %s", xpathTypeName(v))
+		}
+		// A filter expression's predicates count along document order.
+		for _, pred := range e.preds {
+			if ns, err = ev.filter(ns, pred); err != nil {
+				return nil, err
+			}
+		}
+		return ns, nil
+	case xpathPath:
+		var ns nodeSet
+		switch {
+		case e.filter != nil:
+			v, err := ev.eval(e.filter, ctx)
+			if err != nil {
+				return nil, err
+			}
+			var ok bool
+			if ns, ok = v.(nodeSet); !ok {
+				return nil, fmt.Errorf("cannot select from a %s", xpathTypeName(v))
+			}
+		case e.absolute:
+			root := ctx.node.n
+			for root.Parent != nil {
+				root = root.Parent
+			}
+			ns = nodeSet{{root, -1}}
+		default:
+			ns = nodeSet{ctx.node}
+		}
+		for _, step := range e.steps {
+			var err error
+			if ns, err = ev.step(ns, step); err != nil {
+				return nil, err
+			}
+		}
+		return ns, nil
+	}
+	return nil, fmt.Errorf("unknown expression %T", e)
+}
+
+func (ev *xpathEvaluator) evalBinary(e xpathBinary, ctx xpathContext) (interface{}, error) {
+	l, err := ev.eval(e.l, ctx)
+	if err != nil {
+		return nil, err
+	}
+	// and and or do not evaluate their right operand unless they must.
+	switch e.op {
+	case "and":
+		if !xpathBoolean(l) {
+			return false, nil
+		}
+	case "or":
+		if xpathBoolean(l) {
+			return true, nil
+		}
+	}
+	r, err := ev.eval(e.r, ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "and", "or":
+		return xpathBoolean(r), nil
+	case "|":
+		a, ok1 := l.(nodeSet)
+		b, ok2 := r.(nodeSet)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("| needs two node-sets")
+		}
+		return ev.sorted(append(append(nodeSet(nil), a...), b...)), nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compareXPath(e.op, l, r), nil
+	}
+	x, y := xpathNumber(l), xpathNumber(r)
+	switch e.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "div":
+		return x / y, nil
+	}
+	return math.Mod(x, y), nil // mod truncates like Go's %, keeping the dividend's sign
+}
+
+// compareXPath implements the comparison rules of XPath 1.0: a node-set
+// compares true if any of its members does.
+func compareXPath(op string, l, r interface{}) bool {
+	ln, lok := l.(nodeSet)
+	rn, rok := r.(nodeSet)
+	switch {
+	case lok && rok:
+		for _, a := range ln {
+			for _, b := range rn {
+				if compareAtoms(op, xnodeString(a), xnodeString(b)) {
+					return true
+				}
+			}
+		}
+		return false
+	case lok || rok:
+		ns, other := ln, r
+		if rok {
+			ns, other = rn, l
+		}
+		if _, ok := other.(bool); ok {
+			return compareAtoms(op, xpathBoolean(l), xpathBoolean(r))
+		}
+		for _, n := range ns {
+			var atom interface{} = xnodeString(n)
+			if _, ok := other.(float64); ok {
+				atom = xpathNumber(atom)
+			}
+			if lok && compareAtoms(op, atom, other) || rok && compareAtoms(op, other, atom) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, l, r)
+}
+
+// compareAtoms compares two strings, numbers or booleans.
+func compareAtoms(op string, l, r interface{}) bool {
+	if op == "=" || op == "!=" {
+		var eq bool
+		_, lb := l.(bool)
+		_, rb := r.(bool)
+		_, lf := l.(float64)
+		_, rf := r.(float64)
+		switch {
+		case lb || rb:
+			eq = xpathBoolean(l) == xpathBoolean(r)
+		case lf || rf:
+			eq = xpathNumber(l) == xpathNumber(r)
+		default:
+			eq = xpathString(l) == xpathString(r)
+		}
+		return eq == (op == "=")
+	}
+	x, y := xpathNumber(l), xpathNumber(r)
+	switch op {
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	}
+	return x >= y
+}
+
+// step applies one location step to every node of ns.
+func (ev *xpathEvaluator) step(ns nodeSet, s xpathStep) (nodeSet, error) {
+	var out nodeSet
+	for _, n := range ns {
+		// The axis yields nodes in its own direction, which is what
+		// position() counts in the predicates.
+		var selected nodeSet
+		for _, c := range axisNodes(s.axis, n) {
+			if s.test.matches(c, s.axis) {
+				selected = append(selected, c)
+			}
+		}
+		for _, pred := range s.preds {
+			var err error
+			if selected, err = ev.filter(selected, pred); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, selected...)
+	}
+	if len(ns) == 1 && !reverseAxis(s.axis) {
+		return out, nil // already in document order without duplicates
+	}
+	return ev.sorted(out), nil
+}
+
+// filter keeps the nodes of ns for which pred holds. A number is true
+// only at that position.
+func (ev *xpathEvaluator) filter(ns nodeSet, pred xpathExpr) (nodeSet, error) {
+	var kept nodeSet
+	for i, n := range ns {
+		v, err := ev.eval(pred, xpathContext{n, i + 1, len(ns)})
+		if err != nil {
+			return nil, err
+		}
+		ok := false
+		if f, isNum := v.(float64); isNum {
+			ok = f == float64(i+1)
+		} else {
+			ok = xpathBoolean(v)
+		}
+		if ok {
+			kept = append(kept, n)
+		}
+	}
+	return kept, nil
+}
+
+func reverseAxis(axis string) bool {
+	switch axis {
+	case "ancestor", "ancestor-or-self", "preceding", "preceding-sibling":
+		return true
+	}
+	return false
+}
+
+// isXPathNode reports whether n is visible to XPath; doctypes are not.
+func isXPathNode(n *html.Node) bool {
+	return n.Type != html.DoctypeNode && n.Type != html.RawNode
+}
+
+// axisNodes returns the nodes on axis from n, nearest first.
+func axisNodes(axis string, n xnode) nodeSet {
+	var out nodeSet
+	add := func(h *html.Node) {
+		if isXPathNode(h) {
+			out = append(out, xnode{h, -1})
+		}
+	}
+	var descend func(h *html.Node)
+	descend = func(h *html.Node) {
+		for c := h.FirstChild; c != nil; c = c.NextSibling {
+			add(c)
+			descend(c)
+		}
+	}
+	// An attribute's parent is its element, but it is nobody's child.
+	isAttr := n.attr >= 0
+	switch axis {
+	case "self":
+		out = append(out, n)
+	case "attribute":
+		if !isAttr && n.n.Type == html.ElementNode {
+			for i := range n.n.Attr {
+				out = append(out, xnode{n.n, i})
+			}
+		}
+	case "child", "descendant", "descendant-or-self":
+		if axis == "descendant-or-self" {
+			out = append(out, n)
+		}
+		if isAttr {
+			break
+		}
+		if axis == "child" {
+			for c := n.n.FirstChild; c != nil; c = c.NextSibling {
+				add(c)
+			}
+		} else {
+			descend(n.n)
+		}
+	case "parent":
+		if isAttr {
+			add(n.n)
+		} else if n.n.Parent != nil {
+			add(n.n.Parent)
+		}
+	case "ancestor", "ancestor-or-self":
+		if axis == "ancestor-or-self" {
+			out = append(out, n)
+		}
+		p := n.n.Parent
+		if isAttr {
+			p = n.n
+		}
+		for ; p != nil; p = p.Parent {
+			add(p)
+		}
+	case "following-sibling", "preceding-sibling":
+		if isAttr {
+			break
+		}
+		for s := siblingOf(n.n, axis); s != nil; s = siblingOf(s, axis) {
+			add(s)
+		}
+	case "following":
+		if isAttr {
+			descend(n.n) // an element's attributes precede its children
+		}
+		for h := n.n; h != nil; h = h.Parent {
+			for s := h.NextSibling; s != nil; s = s.NextSibling {
+				add(s)
+				descend(s)
+			}
+		}
+	case "preceding":
+		// Everything before n in document order except its ancestors,
+		// nearest first.
+		root := n.n
+		ancestors := make(map[*html.Node]bool)
+		for ; root.Parent != nil; root = root.Parent {
+			ancestors[root.Parent] = true
+		}
+		var before []*html.Node
+		walkNodes(root, func(h *html.Node) walkAction {
+			if h == n.n {
+				return walkStop
+			}
+			if !ancestors[h] {
+				before = append(before, h)
+			}
+			return walkContinue
+		}, nil)
+		for i := len(before) - 1; i >= 0; i-- {
+			add(before[i])
+		}
+	}
+	return out
+}
+
+func siblingOf(n *html.Node, axis string) *html.Node {
+	if axis == "following-sibling" {
+		return n.NextSibling
+	}
+	return n.PrevSibling
+}
+
+// matches reports whether n passes the node test on axis. A name test
+// matches the axis's principal node type, attributes on the attribute
+// axis and elements elsewhere; element names match case-insensitively.
+func (t xpathNodeTest) matches(n xnode, axis string) bool {
+	switch t.kind {
+	case "node":
+		return true
+	case "text":
+		return n.attr < 0 && n.n.Type == html.TextNode
+	case "comment":
+		return n.attr < 0 && n.n.Type == html.CommentNode
+	case "processing-instruction":
+		return false // HTML parses them as comments
+	}
+	if axis == "attribute" {
+		return n.attr >= 0 && (t.name == "*" || t.name == n.n.Attr[n.attr].Key)
+	}
+	return n.attr < 0 && n.n.Type == html.ElementNode && (t.name == "*" || strings.EqualFold(t.name, n.n.Data))
+}
+
+// sorted puts ns in document order and removes duplicates.
+func (ev *xpathEvaluator) sorted(ns nodeSet) nodeSet {
+	if len(ns) < 2 {
+		return ns
+	}
+	if ev.order == nil {
+		ev.order = make(map[*html.Node]int)
+	}
+	if _, ok := ev.order[ns[0].n]; !ok {
+		root := ns[0].n
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		i := len(ev.order)
+		forEachNode(root, func(n *html.Node) {
+			ev.order[n] = i
+			i++
+		}, nil)
+	}
+	sort.SliceStable(ns, func(i, j int) bool {
+		a, b := ev.order[ns[i].n], ev.order[ns[j].n]
+		if a != b {
+			return a < b
+		}
+		return ns[i].attr < ns[j].attr
+	})
+	out := ns[:1]
+	for _, n := range ns[1:] {
+		if n != out[len(out)-1] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Conversions between the four XPath types.
+
+func xpathTypeName(v interface{}) string {
+	switch v.(type) {
+	case nodeSet, []XPathNode:
+		return "node-set"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	}
+	return "boolean"
+}
+
+func xnodeString(n xnode) string {
+	if n.attr >= 0 {
+		return n.n.Attr[n.attr].Val
+	}
+	switch n.n.Type {
+	case html.TextNode, html.CommentNode:
+		return n.n.Data
+	}
+	var b strings.Builder
+	forEachNode(n.n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}, nil)
+	return b.String()
+}
+
+func xpathString(v interface{}) string {
+	switch v := v.(type) {
+	case nodeSet:
+		if len(v) == 0 {
+			return ""
+		}
+		return xnodeString(v[0])
+	case []XPathNode:
+		if len(v) == 0 {
+			return ""
+		}
+		if v[0].Attr != nil {
+			return v[0].Attr.Val
+		}
+		return xnodeString(xnode{v[0].Node, -1})
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	f := v.(float64)
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func xpathNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	// XPath numbers have no exponent, sign or hex form, unlike ParseFloat's.
+	s := strings.Trim(xpathString(v), " \t\r\n")
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || strings.Trim(digits, "0123456789.") != "" || strings.Count(digits, ".") > 1 || digits == "." {
+		return math.NaN()
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func xpathBoolean(v interface{}) bool {
+	switch v := v.(type) {
+	case nodeSet:
+		return len(v) > 0
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	}
+	return v.(bool)
+}
+
+// call evaluates a core library function. parseXPath has already checked
+// the name and the number of arguments.
+func (ev *xpathEvaluator) call(c xpathCall, ctx xpathContext) (interface{}, error) {
+	args := make([]interface{}, len(c.args))
+	for i, a := range c.args {
+		v, err := ev.eval(a, ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	// Functions that take an optional argument default to the context node.
+	arg0 := func() interface{} {
+		if len(args) == 0 {
+			return nodeSet{ctx.node}
+		}
+		return args[0]
+	}
+	nodeArg := func() (nodeSet, error) {
+		ns, ok := arg0().(nodeSet)
+		if !ok {
+			return nil, fmt.Errorf("%s() needs a node-set, not a %s", c.name, xpathTypeName(arg0()))
+		}
+		return ns, nil
+	}
+	str := func(i int) string { return xpathString(args[i]) }
+
+	switch c.name {
+	case "last":
+		return float64(ctx.size), nil
+	case "position":
+		return float64(ctx.pos), nil
+	case "count":
+		ns, err := nodeArg()
+		return float64(len(ns)), err
+	case "id":
+		var ids []string
+		if ns, ok := args[0].(nodeSet); ok {
+			for _, n := range ns {
+				ids = append(ids, strings.Fields(xnodeString(n))...)
+			}
+		} else {
+			ids = strings.Fields(str(0))
+		}
+		root := ctx.node.n
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		var found nodeSet
+		for _, id := range ids {
+			if n := ElementByID(root, id); n != nil {
+				found = append(found, xnode{n, -1})
+			}
+		}
+		return ev.sorted(found), nil
+	case "local-name", "name", "namespace-uri":
+		ns, err := nodeArg()
+		if err != nil || len(ns) == 0 || c.name == "namespace-uri" {
+			return "", err
+		}
+		n := ns[0]
+		switch {
+		case n.attr >= 0:
+			return n.n.Attr[n.attr].Key, nil
+		case n.n.Type == html.ElementNode:
+			return n.n.Data, nil
+		}
+		return "", nil
+	case "string":
+		return xpathString(arg0()), nil
+	case "concat":
+		var b strings.Builder
+		for i := range args {
+			b.WriteString(str(i))
+		}
+		return b.String(), nil
+	case "starts-with":
+		return strings.HasPrefix(str(0), str(1)), nil
+	case "contains":
+		return strings.Contains(str(0), str(1)), nil
+	case "substring-before":
+		if i := strings.Index(str(0), str(1)); i >= 0 {
+			return str(0)[:i], nil
+		}
+		return "", nil
+	case "substring-after":
+		if i := strings.Index(str(0), str(1)); i >= 0 {
+			return str(0)[i+len(str(1)):], nil
+		}
+		return "", nil
+	case "substring":
+		return xpathSubstring(str(0), args[1:]), nil
+	case "string-length":
+		return float64(len([]rune(xpathString(arg0())))), nil
+	case "normalize-space":
+		return strings.Join(strings.Fields(xpathString(arg0())), " "), nil
+	case "translate":
+		return xpathTranslate(str(0), str(1), str(2)), nil
+	case "boolean":
+		return xpathBoolean(args[0]), nil
+	case "not":
+		return !xpathBoolean(args[0]), nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "lang":
+		return xpathLang(ctx.node, str(0)), nil
+	case "number":
+		return xpathNumber(arg0()), nil
+	case "sum":
+		ns, err := nodeArg()
+		var sum float64
+		for _, n := range ns {
+			sum += xpathNumber(xnodeString(n))
+		}
+		return sum, err
+	case "floor":
+		return math.Floor(xpathNumber(args[0])), nil
+	case "ceiling":
+		return math.Ceil(xpathNumber(args[0])), nil
+	case "round":
+		return xpathRound(xpathNumber(args[0])), nil
+	}
+	return nil, fmt.Errorf("unknown function %s()", c.name)
+}
+
+// xpathRound rounds halves towards positive infinity, as XPath requires.
+func xpathRound(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f == 0 {
+		return f
+	}
+	if f < 0 && f >= -0.5 {
+		return math.Copysign(0, -1)
+	}
+	return math.Floor(f + 0.5)
+}
+
+// xpathSubstring returns the characters of s from the rounded start, a
+// 1-based position, for the rounded length if given.
+func xpathSubstring(s string, args []interface{}) string {
+	start := xpathRound(xpathNumber(args[0]))
+	end := math.Inf(1)
+	if len(args) > 1 {
+		end = start + xpathRound(xpathNumber(args[1]))
+	}
+	var b strings.Builder
+	for i, r := range []rune(s) {
+		if p := float64(i + 1); p >= start && p < end {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// xpathTranslate replaces each character of s found in from by the
+// character at the same position in to, or drops it if to is shorter.
+func xpathTranslate(s, from, to string) string {
+	f, t := []rune(from), []rune(to)
+	var b strings.Builder
+	for _, r := range s {
+		i := 0
+		for i < len(f) && f[i] != r {
+			i++
+		}
+		switch {
+		case i == len(f):
+			b.WriteRune(r)
+		case i < len(t):
+			b.WriteRune(t[i])
+		}
+	}
+	return b.String()
+}
+
+// xpathLang reports whether the nearest lang attribute around n names
+// lang or one of its sublanguages.
+func xpathLang(n xnode, lang string) bool {
+	for h := n.n; h != nil; h = h.Parent {
+		if v, ok := attr(h, "lang"); ok {
+			v = strings.ToLower(v)
+			lang = strings.ToLower(lang)
+			return v == lang || strings.HasPrefix(v, lang+"-")
+		}
+	}
+	return false
+}